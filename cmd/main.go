@@ -1,74 +1,237 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	_ "github.com/lib/pq"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/config"
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/handler"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/logging"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/metrics"
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/middleware"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/service"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/tracing"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/migrations"
 )
 
 func main() {
 	cfg := config.Load()
 
-	db, err := sql.Open("postgres",
+	logger, err := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	// Anything still written through the standard log package ends up in the same format.
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:     cfg.TracingExporter,
+		OTLPEndpoint: cfg.TracingOTLPEndpoint,
+		ServiceName:  cfg.TracingServiceName,
+	})
+	if err != nil {
+		fatal(logger, "Failed to set up tracing", err)
+	}
+
+	db, err := tracing.OpenDB("postgres",
 		fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 			cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName))
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal(logger, "Failed to connect to database", err)
 	}
-	defer db.Close()
 
-	if err := runMigrations(db, cfg); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+	if err := runMigrations(db, cfg, logger); err != nil {
+		fatal(logger, "Failed to run migrations", err)
+	}
+	migrationVersion, err := latestMigrationVersion()
+	if err != nil {
+		fatal(logger, "Failed to read migrations", err)
 	}
 
 	userRepo := repository.NewUserRepository(db)
+	if len(cfg.AdminUserIDs) > 0 {
+		if err := userRepo.PromoteToAdmin(context.Background(), cfg.AdminUserIDs); err != nil {
+			fatal(logger, "Failed to promote admin users", err)
+		}
+	}
 	transactionRepo := repository.NewTransactionRepository(db)
-	merchRepo := repository.NewMerchRepository() 
+	merchRepo := repository.NewMerchRepository(db)
+	cartRepo := repository.NewCartRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	ledgerRepo := repository.NewLedgerRepository(db)
+	scheduledTransferRepo := repository.NewScheduledTransferRepository(db)
+	moneyRequestRepo := repository.NewMoneyRequestRepository(db)
+	holdRepo := repository.NewHoldRepository(db)
+	transferLimitRepo := repository.NewTransferLimitRepository(db)
+	promotionRepo := repository.NewPromotionRepository(db)
 
-	authService := service.NewAuthService(userRepo, cfg.JWTSecret)
-	walletService := service.NewWalletService(userRepo, transactionRepo, db)
-	merchService := service.NewMerchService(merchRepo, userRepo, db)
+	authService := service.NewAuthService(userRepo, sessionRepo, db, service.AuthOptions{
+		JWTSecret:        cfg.JWTSecret,
+		AccessTokenTTL:   cfg.AccessTokenTTL,
+		RefreshTokenTTL:  cfg.RefreshTokenTTL,
+		AllowUserIDLogin: cfg.AllowUserIDLogin,
+		MaxFailedLogins:  cfg.MaxFailedLogins,
+		LockoutDuration:  cfg.LockoutDuration,
+	}, logger)
+	walletService := service.NewWalletService(userRepo, transactionRepo, db, cfg.TransferReversalWindow, logger)
+	merchService := service.NewMerchService(merchRepo, userRepo, db, logger)
+	cartService := service.NewCartService(cartRepo, merchRepo, db, logger)
+	ledgerService := service.NewLedgerService(ledgerRepo)
+	transferLimitService := service.NewTransferLimitService(transferLimitRepo, userRepo)
+	promotionService := service.NewPromotionService(promotionRepo)
+	holdService := service.NewHoldService(holdRepo, merchRepo, db, cfg.HoldTTL, logger)
+	moneyRequestService := service.NewMoneyRequestService(moneyRequestRepo, userRepo, db, cfg.MoneyRequestTTL, logger)
+	scheduleService := service.NewScheduleService(scheduledTransferRepo, userRepo, walletService, db, logger)
 
-	r := gin.Default()
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	})
+	idempotencyService := service.NewIdempotencyService(repository.NewIdempotencyRepository(db), cfg.IdempotencyKeyTTL, logger)
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		scheduleService.Run(workerCtx, cfg.SchedulerPollInterval)
+	}()
+	go func() {
+		defer workers.Done()
+		idempotencyService.Run(workerCtx, cfg.IdempotencyPurgeInterval)
+	}()
+
+	healthService := service.NewHealthService(repository.NewHealthRepository(db), scheduleService, migrationVersion, cfg.HealthCheckTimeout)
+
+	metrics.RegisterDB(db, cfg.DBName)
+
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.Logger(logger), middleware.Recovery(logger))
+	// Probes would otherwise produce a trace every few seconds.
+	r.Use(otelgin.Middleware(cfg.TracingServiceName, otelgin.WithFilter(func(req *http.Request) bool {
+		switch req.URL.Path {
+		case "/livez", "/readyz", "/health":
+			return false
+		}
+		return true
+	})))
+	r.Use(middleware.Metrics())
+	healthHandler := handler.NewHealthHandler(healthService)
+	r.GET("/livez", healthHandler.Livez)
+	r.GET("/readyz", healthHandler.Readyz)
+	// /health is kept for load balancers configured before /readyz existed.
+	r.GET("/health", healthHandler.Readyz)
 
-	
 	authHandler := handler.NewAuthHandler(authService)
 	r.POST("/auth", authHandler.Login)
+	r.POST("/auth/register", authHandler.Register)
+	r.POST("/auth/refresh", authHandler.Refresh)
+	r.POST("/auth/logout", authHandler.Logout)
 
-	
-	authMiddleware := middleware.JWTAuthMiddleware(cfg.JWTSecret)
+	authMiddleware := middleware.JWTAuthMiddleware(cfg.JWTSecret, authService)
 
-	
 	authorized := r.Group("/api")
 	authorized.Use(authMiddleware)
 	{
 		walletHandler := handler.NewWalletHandler(walletService)
+		authorized.POST("/password", authHandler.ChangePassword)
+
 		authorized.POST("/transfer", walletHandler.Transfer)
+		authorized.POST("/transfer/batch", walletHandler.TransferBatch)
+		authorized.POST("/transfers/:id/cancel", walletHandler.CancelTransfer)
 		authorized.GET("/wallet", walletHandler.GetWallet)
 		authorized.GET("/wallet/history", walletHandler.GetWalletHistory)
 
+		moneyRequestHandler := handler.NewMoneyRequestHandler(moneyRequestService)
+		authorized.POST("/money-requests", moneyRequestHandler.Create)
+		authorized.GET("/money-requests/incoming", moneyRequestHandler.ListIncoming)
+		authorized.GET("/money-requests/outgoing", moneyRequestHandler.ListOutgoing)
+		authorized.POST("/money-requests/:id/accept", moneyRequestHandler.Accept)
+		authorized.POST("/money-requests/:id/decline", moneyRequestHandler.Decline)
+		authorized.POST("/money-requests/:id/cancel", moneyRequestHandler.Cancel)
+
+		scheduledTransferHandler := handler.NewScheduledTransferHandler(scheduleService)
+		authorized.POST("/scheduled-transfers", scheduledTransferHandler.Create)
+		authorized.GET("/scheduled-transfers", scheduledTransferHandler.List)
+		authorized.POST("/scheduled-transfers/:id/pause", scheduledTransferHandler.Pause)
+		authorized.POST("/scheduled-transfers/:id/resume", scheduledTransferHandler.Resume)
+		authorized.POST("/scheduled-transfers/:id/cancel", scheduledTransferHandler.Cancel)
+		authorized.GET("/scheduled-transfers/:id/runs", scheduledTransferHandler.ListRuns)
+
 		merchHandler := handler.NewMerchHandler(merchService)
 		authorized.GET("/merch", merchHandler.ListMerch)
 		authorized.POST("/purchase", merchHandler.PurchaseMerch)
-		authorized.GET("/purchases", merchHandler.ListPurchases) 
+		authorized.GET("/purchases", merchHandler.ListPurchases)
+
+		holdHandler := handler.NewHoldHandler(holdService)
+		authorized.POST("/holds", holdHandler.Create)
+		authorized.GET("/holds", holdHandler.List)
+		authorized.POST("/holds/:id/capture", holdHandler.Capture)
+		authorized.POST("/holds/:id/release", holdHandler.Release)
+
+		cartHandler := handler.NewCartHandler(cartService)
+		authorized.GET("/cart", cartHandler.GetCart)
+		authorized.POST("/cart/items", cartHandler.AddItem)
+		authorized.DELETE("/cart/items/:item_name", cartHandler.RemoveItem)
+		authorized.POST("/checkout", cartHandler.Checkout)
+
+		userHandler := handler.NewUserHandler(userRepo, transactionRepo)
+		ledgerHandler := handler.NewLedgerHandler(ledgerService)
+		transferLimitHandler := handler.NewTransferLimitHandler(transferLimitService)
+		promotionHandler := handler.NewPromotionHandler(promotionService)
+
+		// Auditors can read everything under /api/admin, only admins can change anything.
+		admin := authorized.Group("/admin")
+		admin.Use(middleware.RequireRole(model.RoleAdmin, model.RoleAuditor))
+		{
+			admin.GET("/merch", merchHandler.AdminListMerch)
+			admin.GET("/users/:user_id", userHandler.GetUser)
+			admin.GET("/users/:user_id/purchases", merchHandler.ListPurchasesByUserID)
+			admin.GET("/ledger/check", ledgerHandler.CheckBooks)
+			admin.GET("/transfer-limits", transferLimitHandler.List)
+			admin.GET("/orders", merchHandler.ListOrders)
+			admin.GET("/promotions", promotionHandler.List)
+
+			adminWrite := admin.Group("")
+			adminWrite.Use(middleware.RequireRole(model.RoleAdmin))
+			adminWrite.POST("/merch", merchHandler.CreateMerchItem)
+			adminWrite.PUT("/merch/:id", merchHandler.UpdateMerchItem)
+			adminWrite.POST("/merch/:id/deactivate", merchHandler.DeactivateMerchItem)
+			adminWrite.POST("/merch/:id/restock", merchHandler.RestockMerchItem)
+			adminWrite.POST("/merch/:id/variants", merchHandler.CreateMerchVariant)
+			adminWrite.PUT("/merch/:id/variants/:variant_id", merchHandler.UpdateMerchVariant)
+			adminWrite.POST("/merch/:id/variants/:variant_id/restock", merchHandler.RestockMerchVariant)
+			adminWrite.POST("/users/:user_id/purchases/:item_name", merchHandler.CreatePurchaseForUser)
+			adminWrite.POST("/users/:user_id/balance", walletHandler.AdjustBalance)
+			adminWrite.POST("/transfers/:id/reverse", walletHandler.ReverseTransfer)
+			adminWrite.POST("/purchases/:id/refund", merchHandler.RefundPurchase)
+			adminWrite.POST("/purchases/:id/status", merchHandler.TransitionOrder)
+			adminWrite.POST("/holds/:id/capture", holdHandler.AdminCapture)
+			adminWrite.POST("/holds/:id/release", holdHandler.AdminRelease)
+			adminWrite.PUT("/transfer-limits/:scope", transferLimitHandler.Update)
+			adminWrite.PUT("/users/:user_id/transfer-limits", transferLimitHandler.UpdateForUser)
+			adminWrite.DELETE("/users/:user_id/transfer-limits", transferLimitHandler.DeleteForUser)
+			adminWrite.POST("/promotions", promotionHandler.Create)
+			adminWrite.POST("/promotions/:id/deactivate", promotionHandler.Deactivate)
+			adminWrite.PUT("/users/:user_id/role", authHandler.SetRole)
+			adminWrite.POST("/users/:user_id/sessions/revoke", authHandler.RevokeUserSessions)
+		}
 	}
 
 	server := &http.Server{
@@ -79,22 +242,119 @@ func main() {
 		IdleTimeout:  120 * time.Second,
 	}
 
-	log.Printf("Server starting on port 8080")
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	// Metrics are served on a separate listener that is not exposed to the public.
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+	metricsServer := &http.Server{
+		Addr:         cfg.MetricsAddr,
+		Handler:      metricsMux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  120 * time.Second,
 	}
+
+	serverErr := make(chan error, 2)
+	for _, srv := range []*http.Server{server, metricsServer} {
+		go func(srv *http.Server) {
+			logger.Info("Server starting", "addr", srv.Addr)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+		}(srv)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-serverErr:
+		fatal(logger, "Server failed to start", err)
+	case sig := <-signals:
+		logger.Info("Shutting down", "signal", sig.String())
+	}
+
+	shutdown(cfg, logger, []*http.Server{server, metricsServer}, healthService, stopWorkers, &workers)
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Error("Failed to flush traces", "error", err)
+	}
+	cancelFlush()
+
+	if err := db.Close(); err != nil {
+		logger.Error("Failed to close database", "error", err)
+	}
+	logger.Info("Server stopped")
 }
 
-func runMigrations(db *sql.DB, cfg *config.Config) error {
+// fatal logs err and exits. Deferred functions do not run.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// shutdown drains the instance. Readiness fails first so the load balancer stops routing to it,
+// then the servers stop accepting connections and wait for in-flight requests, and the
+// background workers finish what they are doing. Waiting is bounded by cfg.ShutdownTimeout.
+func shutdown(cfg *config.Config, logger *slog.Logger, servers []*http.Server, healthService *service.HealthService, stopWorkers context.CancelFunc, workers *sync.WaitGroup) {
+	healthService.ShutDown()
+	time.Sleep(cfg.ShutdownDrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	stopWorkers()
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error("Failed to finish in-flight requests", "addr", server.Addr, "error", err)
+		}
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-ctx.Done():
+		logger.Warn("Background workers did not stop before the shutdown deadline")
+	}
+}
+
+// latestMigrationVersion returns the version of the newest embedded migration,
+// which is the schema version this binary expects.
+func latestMigrationVersion() (uint, error) {
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	latest, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(latest)
+		if errors.Is(err, fs.ErrNotExist) {
+			return latest, nil
+		} else if err != nil {
+			return 0, err
+		}
+		latest = next
+	}
+}
+
+func runMigrations(db *sql.DB, cfg *config.Config, logger *slog.Logger) error {
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
 	if err != nil {
 		return fmt.Errorf("failed to create migration driver: %w", err)
 	}
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://migrations",
-		cfg.DBName,
-		driver,
-	)
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", src, cfg.DBName, driver)
 	if err != nil {
 		return fmt.Errorf("failed to create migration instance: %w", err)
 	}
@@ -103,13 +363,13 @@ func runMigrations(db *sql.DB, cfg *config.Config) error {
 		if err := m.Down(); err != nil && err != migrate.ErrNoChange {
 			return fmt.Errorf("failed to drop database: %w", err)
 		}
-		log.Println("Migrations dropped")
+		logger.Info("Migrations dropped")
 		return nil
 	}
 
 	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
 		return fmt.Errorf("failed to run migrations up: %w", err)
 	}
-	log.Println("Migrations successfully applied")
+	logger.Info("Migrations successfully applied")
 	return nil
 }
@@ -1,31 +1,149 @@
-
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Config struct {
- DBHost     string
- DBPort     string
- DBUser     string
- DBPassword string
- DBName     string
- JWTSecret  string
+	DBHost     string
+	DBPort     string
+	DBUser     string
+	DBPassword string
+	DBName     string
+	JWTSecret  string
+	// AdminUserIDs are granted the admin role on startup to bootstrap the first admins.
+	AdminUserIDs []int
+
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// AllowUserIDLogin keeps the passwordless login by user ID; enable it for local development only.
+	AllowUserIDLogin bool
+	MaxFailedLogins  int
+	LockoutDuration  time.Duration
+
+	// TransferReversalWindow is how long after a transfer its sender may cancel it.
+	TransferReversalWindow time.Duration
+	// MoneyRequestTTL is how long a money request stays pending before it expires.
+	MoneyRequestTTL time.Duration
+	// HoldTTL is how long a hold reserves coins before it expires.
+	HoldTTL time.Duration
+	// SchedulerPollInterval is how often each replica looks for due scheduled transfers.
+	SchedulerPollInterval time.Duration
+
+	// IdempotencyKeyTTL is how long an Idempotency-Key is remembered; a retry after that runs again.
+	IdempotencyKeyTTL time.Duration
+	// IdempotencyPurgeInterval is how often expired idempotency keys are deleted.
+	IdempotencyPurgeInterval time.Duration
+
+	// MetricsAddr is where the internal listener serving /metrics binds. It is kept off the
+	// public port, so only the scraper inside the network can read the metrics.
+	MetricsAddr string
+
+	// HealthCheckTimeout bounds each readiness check that talks to the database.
+	HealthCheckTimeout time.Duration
+
+	// ShutdownDrainDelay is how long the server keeps serving after readiness starts failing,
+	// so the load balancer stops routing to it before it stops accepting connections.
+	ShutdownDrainDelay time.Duration
+	// ShutdownTimeout bounds the wait for in-flight requests and background workers on shutdown.
+	ShutdownTimeout time.Duration
+
+	// LogLevel is one of "debug", "info", "warn" or "error"; LogFormat is "json" or "text".
+	LogLevel  string
+	LogFormat string
+
+	// TracingExporter is where spans go: "stdout", "otlp", or "none" to disable tracing.
+	TracingExporter string
+	// TracingOTLPEndpoint is the host:port of the OTLP/HTTP collector used by the "otlp" exporter.
+	TracingOTLPEndpoint string
+	TracingServiceName  string
 }
+
 func Load() *Config {
 	return &Config{
-	 DBHost:     getEnv("DB_HOST", "localhost"),
-	 DBPort:     getEnv("DB_PORT", "5432"),
-	 DBUser:     getEnv("DB_USER", "postgres"),
-	 DBPassword: getEnv("DB_PASSWORD", "postgres"),
-	 DBName:     getEnv("DB_NAME", "avito_merch"),
-	 JWTSecret:  getEnv("JWT_SECRET", "secret"),
+		DBHost:       getEnv("DB_HOST", "localhost"),
+		DBPort:       getEnv("DB_PORT", "5432"),
+		DBUser:       getEnv("DB_USER", "postgres"),
+		DBPassword:   getEnv("DB_PASSWORD", "postgres"),
+		DBName:       getEnv("DB_NAME", "avito_merch"),
+		JWTSecret:    getEnv("JWT_SECRET", "secret"),
+		AdminUserIDs: getEnvIntList("ADMIN_USER_IDS"),
+
+		AccessTokenTTL:  getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		AllowUserIDLogin: getEnvBool("AUTH_ALLOW_USER_ID_LOGIN", false),
+		MaxFailedLogins:  getEnvInt("AUTH_MAX_FAILED_LOGINS", 5),
+		LockoutDuration:  getEnvDuration("AUTH_LOCKOUT_DURATION", 15*time.Minute),
+
+		TransferReversalWindow: getEnvDuration("TRANSFER_REVERSAL_WINDOW", 15*time.Minute),
+		MoneyRequestTTL:        getEnvDuration("MONEY_REQUEST_TTL", 7*24*time.Hour),
+		HoldTTL:                getEnvDuration("HOLD_TTL", 72*time.Hour),
+		SchedulerPollInterval:  getEnvDuration("SCHEDULER_POLL_INTERVAL", 10*time.Second),
+
+		IdempotencyKeyTTL:        getEnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+		IdempotencyPurgeInterval: getEnvDuration("IDEMPOTENCY_PURGE_INTERVAL", time.Hour),
+
+		MetricsAddr: getEnv("METRICS_ADDR", ":9090"),
+
+		HealthCheckTimeout: getEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+
+		ShutdownDrainDelay: getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+		ShutdownTimeout:    getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
+		LogFormat: getEnv("LOG_FORMAT", "json"),
+
+		TracingExporter:     getEnv("TRACING_EXPORTER", "none"),
+		TracingOTLPEndpoint: getEnv("TRACING_OTLP_ENDPOINT", "localhost:4318"),
+		TracingServiceName:  getEnv("TRACING_SERVICE_NAME", "avito-merch"),
 	}
-   }
-   
-   func getEnv(key, defaultVal string) string {
+}
+
+func getEnv(key, defaultVal string) string {
 	if value, exists := os.LookupEnv(key); exists {
-	 return value
+		return value
 	}
 	return defaultVal
-   }
-   
\ No newline at end of file
+}
+
+func getEnvInt(key string, defaultVal int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return defaultVal
+	}
+	return value
+}
+
+func getEnvBool(key string, defaultVal bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return defaultVal
+	}
+	return value
+}
+
+// getEnvIntList parses a comma-separated list of integers, skipping malformed entries.
+func getEnvIntList(key string) []int {
+	var values []int
+	for _, part := range strings.Split(getEnv(key, ""), ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	value, err := time.ParseDuration(getEnv(key, ""))
+	if err != nil {
+		return defaultVal
+	}
+	return value
+}
@@ -2,9 +2,11 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/service"
 )
 
@@ -17,6 +19,9 @@ func NewAuthHandler(authService *service.AuthService) *AuthHandler {
 }
 
 type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// UserID is the passwordless development login, accepted only when enabled in config.
 	UserID int `json:"user_id"`
 }
 
@@ -27,16 +32,200 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	if req.UserID <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID must be a positive integer"})
+	var tokens *model.TokenPair
+	var user *model.User
+	var err error
+	if req.Username != "" {
+		tokens, user, err = h.authService.LoginWithPassword(c.Request.Context(), req.Username, req.Password)
+	} else if req.UserID > 0 {
+		tokens, user, err = h.authService.Login(c.Request.Context(), req.UserID)
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username and password are required"})
 		return
 	}
 
-	token, user, err := h.authService.Login(c.Request.Context(), req.UserID)
-	if err != nil {
+	if err == service.ErrInvalidCredentials {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		return
+	} else if err == service.ErrAccountLocked {
+		c.JSON(http.StatusLocked, gin.H{"error": "Account is temporarily locked after too many failed attempts"})
+		return
+	} else if err == service.ErrUserIDLoginDisabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username and password are required"})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token, "userID": user.ID, "coins": user.Coins})
+	respondTokens(c, http.StatusOK, tokens, user)
+}
+
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req RegisterRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	tokens, user, err := h.authService.Register(c.Request.Context(), req.Username, req.Password)
+	if err == service.ErrInvalidUsername {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username must be 3-32 letters, digits, '_', '.' or '-'"})
+		return
+	} else if err == service.ErrWeakPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be 8-72 characters long"})
+		return
+	} else if err == service.ErrUsernameTaken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username is already taken"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register"})
+		return
+	}
+
+	respondTokens(c, http.StatusCreated, tokens, user)
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	tokens, err := h.authService.ChangePassword(c.Request.Context(), int(userID.(float64)), req.CurrentPassword, req.NewPassword)
+	if err == service.ErrWeakPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be 8-72 characters long"})
+		return
+	} else if err == service.ErrInvalidCredentials {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	} else if err == service.ErrAccountLocked {
+		c.JSON(http.StatusLocked, gin.H{"error": "Account is temporarily locked after too many failed attempts"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func respondTokens(c *gin.Context, status int, tokens *model.TokenPair, user *model.User) {
+	c.JSON(status, gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_at":    tokens.ExpiresAt,
+		"userID":        user.ID,
+		"coins":         user.Coins,
+	})
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.BindJSON(&req); err != nil || req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token is required"})
+		return
+	}
+
+	tokens, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err == service.ErrInvalidRefreshToken {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	} else if err == service.ErrRefreshTokenReused {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token was already used, session revoked"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.BindJSON(&req); err != nil || req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token is required"})
+		return
+	}
+
+	err := h.authService.Logout(c.Request.Context(), req.RefreshToken)
+	if err == service.ErrInvalidRefreshToken {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Logged out successfully"})
+}
+
+func (h *AuthHandler) RevokeUserSessions(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	revoked, err := h.authService.RevokeUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "revoked_sessions": revoked})
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+func (h *AuthHandler) SetRole(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	var req SetRoleRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	err = h.authService.SetRole(c.Request.Context(), userID, req.Role)
+	if err == service.ErrInvalidRole {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be one of user, admin, auditor"})
+		return
+	} else if err == service.ErrUserNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "role": req.Role})
 }
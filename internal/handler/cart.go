@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/service"
+)
+
+type CartHandler struct {
+	cartService *service.CartService
+}
+
+func NewCartHandler(cartService *service.CartService) *CartHandler {
+	return &CartHandler{cartService: cartService}
+}
+
+func (h *CartHandler) GetCart(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	cart, err := h.cartService.GetCart(c.Request.Context(), int(userID.(float64)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get cart"})
+		return
+	}
+	c.JSON(http.StatusOK, cart)
+}
+
+type AddCartItemRequest struct {
+	ItemName string `json:"item_name"`
+	Quantity int    `json:"quantity"`
+}
+
+func (h *CartHandler) AddItem(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req AddCartItemRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if req.ItemName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Item name is required"})
+		return
+	}
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+
+	cart, err := h.cartService.AddItem(c.Request.Context(), int(userID.(float64)), req.ItemName, req.Quantity)
+	if err == service.ErrInvalidQuantity {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be positive"})
+		return
+	} else if err == service.ErrMerchNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merch item not found"})
+		return
+	} else if err == service.ErrVariantRequired {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Merch item is sold in variants, buy it directly with a sku"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item to cart"})
+		return
+	}
+	c.JSON(http.StatusOK, cart)
+}
+
+func (h *CartHandler) RemoveItem(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	cart, err := h.cartService.RemoveItem(c.Request.Context(), int(userID.(float64)), c.Param("item_name"))
+	if err == service.ErrCartItemNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item is not in the cart"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove item from cart"})
+		return
+	}
+	c.JSON(http.StatusOK, cart)
+}
+
+// CheckoutRequest optionally names a promo code, applied to every item it discounts most.
+type CheckoutRequest struct {
+	PromoCode string `json:"promo_code"`
+}
+
+func (h *CartHandler) Checkout(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// The body is optional, an empty one checks out without a promo code.
+	var req CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	order, err := h.cartService.Checkout(c.Request.Context(), int(userID.(float64)), req.PromoCode)
+	if err == service.ErrCartEmpty {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
+		return
+	} else if err == service.ErrInsufficientFunds {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient funds"})
+		return
+	} else if err == service.ErrMerchNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart contains an item that is no longer available"})
+		return
+	} else if err == service.ErrOutOfStock {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cart contains an item that is out of stock"})
+		return
+	} else if err == service.ErrVariantRequired {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cart contains an item that is now sold in variants, remove it and buy it directly with a sku"})
+		return
+	} else if respondPromoCodeError(c, err) {
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to checkout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "order": order})
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/service"
+)
+
+type HealthHandler struct {
+	healthService *service.HealthService
+}
+
+func NewHealthHandler(healthService *service.HealthService) *HealthHandler {
+	return &HealthHandler{healthService: healthService}
+}
+
+// Livez only tells that the process serves requests; dependencies are checked by Readyz,
+// so an outage of Postgres takes the instance out of rotation without restarting it.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": model.HealthStatusOK})
+}
+
+// Readyz runs the readiness checks; ?verbose adds the latency of each check.
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	_, verbose := c.GetQuery("verbose")
+
+	report := h.healthService.Readiness(c.Request.Context(), verbose)
+	if report.Status != model.HealthStatusOK {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
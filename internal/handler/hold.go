@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/service"
+)
+
+type HoldHandler struct {
+	holdService *service.HoldService
+}
+
+func NewHoldHandler(holdService *service.HoldService) *HoldHandler {
+	return &HoldHandler{holdService: holdService}
+}
+
+type CreateHoldRequest struct {
+	ItemName string `json:"item_name"`
+}
+
+func (h *HoldHandler) Create(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req CreateHoldRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	hold, err := h.holdService.Create(c.Request.Context(), int(userID.(float64)), req.ItemName)
+	if err == service.ErrMerchNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merch item not found"})
+		return
+	} else if err == service.ErrVariantRequired {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Merch item is sold in variants, it cannot be held"})
+		return
+	} else if err == service.ErrInsufficientFunds {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient funds"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create hold"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, hold)
+}
+
+func (h *HoldHandler) List(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	holds, err := h.holdService.List(c.Request.Context(), int(userID.(float64)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list holds"})
+		return
+	}
+	c.JSON(http.StatusOK, holds)
+}
+
+func (h *HoldHandler) Capture(c *gin.Context) {
+	h.resolveOwn(c, h.holdService.Capture)
+}
+
+func (h *HoldHandler) Release(c *gin.Context) {
+	h.resolveOwn(c, h.holdService.Release)
+}
+
+// AdminCapture captures any user's hold, e.g. when a pre-ordered item arrives.
+func (h *HoldHandler) AdminCapture(c *gin.Context) {
+	h.resolve(c, 0, h.holdService.Capture)
+}
+
+func (h *HoldHandler) AdminRelease(c *gin.Context) {
+	h.resolve(c, 0, h.holdService.Release)
+}
+
+func (h *HoldHandler) resolveOwn(c *gin.Context, resolve func(ctx context.Context, userID int, id int) (*model.Hold, error)) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	h.resolve(c, int(userID.(float64)), resolve)
+}
+
+func (h *HoldHandler) resolve(c *gin.Context, userID int, resolve func(ctx context.Context, userID int, id int) (*model.Hold, error)) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hold ID format"})
+		return
+	}
+
+	hold, err := resolve(c.Request.Context(), userID, id)
+	if err == service.ErrHoldNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Hold not found"})
+		return
+	} else if err == service.ErrHoldExpired {
+		c.JSON(http.StatusConflict, gin.H{"error": "Hold has expired"})
+		return
+	} else if err == service.ErrHoldNotActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Hold is no longer active"})
+		return
+	} else if err == service.ErrOutOfStock {
+		c.JSON(http.StatusConflict, gin.H{"error": "Merch item is out of stock"})
+		return
+	} else if err == service.ErrInsufficientFunds {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient funds"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update hold"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hold)
+}
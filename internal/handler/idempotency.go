@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+var errInvalidIdempotencyKey = errors.New("invalid idempotency key")
+
+// newIdempotencyRecord returns nil if the client did not send an Idempotency-Key header.
+// The fingerprint is taken over the route and the decoded request, so body formatting does not matter.
+func newIdempotencyRecord(c *gin.Context, userID int, req interface{}, status int, response interface{}) (*model.IdempotencyRecord, error) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		return nil, nil
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, errInvalidIdempotencyKey
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	hash := sha256.Sum256([]byte(c.Request.Method + " " + c.FullPath() + "\n" + string(payload)))
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	return &model.IdempotencyRecord{
+		UserID:         userID,
+		Key:            key,
+		RequestHash:    hex.EncodeToString(hash[:]),
+		ResponseStatus: status,
+		ResponseBody:   body,
+	}, nil
+}
+
+func respondIdempotent(c *gin.Context, record *model.IdempotencyRecord) {
+	if record.Replayed {
+		c.Header(idempotentReplayedHeader, "true")
+	}
+	c.Data(record.ResponseStatus, "application/json; charset=utf-8", record.ResponseBody)
+}
@@ -1,132 +1,514 @@
 package handler
 
 import (
- "net/http"
- "strconv"
+	"net/http"
+	"strconv"
 
- "github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin"
 
- "github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
- "github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/service"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/service"
 )
 
 type MerchHandler struct {
- merchService *service.MerchService
+	merchService *service.MerchService
 }
 
 func NewMerchHandler(merchService *service.MerchService) *MerchHandler {
- return &MerchHandler{merchService: merchService}
+	return &MerchHandler{merchService: merchService}
 }
 
 func (h *MerchHandler) ListMerch(c *gin.Context) {
- merchItems, err := h.merchService.ListMerch(c.Request.Context())
- if err != nil {
-  c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list merch"})
-  return
- }
- c.JSON(http.StatusOK, merchItems)
+	merchItems, err := h.merchService.ListMerch(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list merch"})
+		return
+	}
+	c.JSON(http.StatusOK, merchItems)
 }
 
+// PurchaseRequest names the item and, for items sold in variants, the SKU of the variant.
+// PromoCode is optional.
 type PurchaseRequest struct {
- ItemName string `json:"item_name"`
+	ItemName  string `json:"item_name"`
+	SKU       string `json:"sku"`
+	PromoCode string `json:"promo_code"`
 }
 
 func (h *MerchHandler) PurchaseMerch(c *gin.Context) {
- userID, exists := c.Get("userID")
- if !exists {
-  c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-  return
- }
-
- var req PurchaseRequest
- if err := c.BindJSON(&req); err != nil {
-  c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
-  return
- }
-
- if req.ItemName == "" {
-  c.JSON(http.StatusBadRequest, gin.H{"error": "Item name is required"})
-  return
- }
-
- err := h.merchService.PurchaseMerch(c.Request.Context(), int(userID.(float64)), req.ItemName)
- if err == service.ErrInsufficientFunds {
-  c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient funds"})
-  return
- } else if err == service.ErrMerchNotFound {
-  c.JSON(http.StatusNotFound, gin.H{"error": "Merch item not found"})
-  return
- } else if err != nil {
-  c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purchase merch"})
-  return
- }
-
- c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Merch purchased successfully"})
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req PurchaseRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if req.ItemName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Item name is required"})
+		return
+	}
+
+	response := gin.H{"status": "success", "message": "Merch purchased successfully"}
+	idem, err := newIdempotencyRecord(c, int(userID.(float64)), req, http.StatusOK, response)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Idempotency-Key header"})
+		return
+	}
+
+	record, err := h.merchService.PurchaseMerchIdempotent(c.Request.Context(), idem, int(userID.(float64)), req.ItemName, req.SKU, req.PromoCode)
+	if err == service.ErrInsufficientFunds {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient funds"})
+		return
+	} else if err == service.ErrMerchNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merch item not found"})
+		return
+	} else if err == service.ErrVariantNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merch variant not found"})
+		return
+	} else if err == service.ErrVariantRequired {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Merch item is sold in variants, sku is required"})
+		return
+	} else if err == service.ErrOutOfStock {
+		c.JSON(http.StatusConflict, gin.H{"error": "Merch item is out of stock"})
+		return
+	} else if respondPromoCodeError(c, err) {
+		return
+	} else if err == service.ErrIdempotencyKeyReused {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency key was already used with a different request"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purchase merch"})
+		return
+	}
+
+	if record != nil {
+		respondIdempotent(c, record)
+		return
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 func (h *MerchHandler) ListPurchases(c *gin.Context) {
- userID, exists := c.Get("userID")
- if !exists {
-  c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-  return
- }
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	h.listPurchases(c, int(userID.(float64)))
+}
+
+func (h *MerchHandler) listPurchases(c *gin.Context, userID int) {
+	filter, err := parsePurchaseFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters"})
+		return
+	}
+
+	purchases, nextCursor, err := h.merchService.ListPurchases(c.Request.Context(), userID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list purchases"})
+		return
+	}
+	c.JSON(http.StatusOK, PurchaseHistoryResponse{Purchases: purchases, NextCursor: nextCursor})
+}
+
+func (h *MerchHandler) AdminListMerch(c *gin.Context) {
+	merchItems, err := h.merchService.ListAllMerch(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list merch"})
+		return
+	}
+	c.JSON(http.StatusOK, merchItems)
+}
+
+type CreateMerchItemRequest struct {
+	Name        string `json:"name"`
+	Price       int    `json:"price"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+	Stock       *int   `json:"stock"`
+}
+
+func (h *MerchHandler) CreateMerchItem(c *gin.Context) {
+	var req CreateMerchItemRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	item, err := h.merchService.CreateMerchItem(c.Request.Context(), model.Merch{
+		Name:        req.Name,
+		Price:       req.Price,
+		Description: req.Description,
+		Category:    req.Category,
+		Active:      true,
+		Stock:       req.Stock,
+	})
+	if err == service.ErrInvalidMerchItem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required, price must be positive and stock must not be negative"})
+		return
+	} else if err == service.ErrMerchAlreadyExists {
+		c.JSON(http.StatusConflict, gin.H{"error": "Merch item with this name already exists"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create merch item"})
+		return
+	}
+	c.JSON(http.StatusCreated, item)
+}
+
+type UpdateMerchItemRequest struct {
+	Name        *string `json:"name"`
+	Price       *int    `json:"price"`
+	Description *string `json:"description"`
+	Category    *string `json:"category"`
+	Active      *bool   `json:"active"`
+}
+
+func (h *MerchHandler) UpdateMerchItem(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merch item ID format"})
+		return
+	}
+
+	var req UpdateMerchItemRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	item, err := h.merchService.UpdateMerchItem(c.Request.Context(), id, model.MerchUpdate{
+		Name:        req.Name,
+		Price:       req.Price,
+		Description: req.Description,
+		Category:    req.Category,
+		Active:      req.Active,
+	})
+	h.respondMerchItem(c, item, err)
+}
+
+func (h *MerchHandler) DeactivateMerchItem(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merch item ID format"})
+		return
+	}
+
+	item, err := h.merchService.DeactivateMerchItem(c.Request.Context(), id)
+	h.respondMerchItem(c, item, err)
+}
+
+type RestockRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+func (h *MerchHandler) RestockMerchItem(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merch item ID format"})
+		return
+	}
+
+	var req RestockRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	item, err := h.merchService.RestockMerchItem(c.Request.Context(), id, req.Quantity)
+	if err == service.ErrInvalidQuantity {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be positive"})
+		return
+	}
+	h.respondMerchItem(c, item, err)
+}
+
+type CreateMerchVariantRequest struct {
+	SKU           string `json:"sku"`
+	Size          string `json:"size"`
+	Color         string `json:"color"`
+	PriceOverride *int   `json:"price_override"`
+	Stock         *int   `json:"stock"`
+}
+
+func (h *MerchHandler) CreateMerchVariant(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merch item ID format"})
+		return
+	}
+
+	var req CreateMerchVariantRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
 
- purchases, err := h.merchService.ListPurchases(c.Request.Context(), int(userID.(float64)))
- if err != nil {
-  c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list purchases"})
-  return
- }
- c.JSON(http.StatusOK, purchases)
+	variant, err := h.merchService.CreateVariant(c.Request.Context(), id, model.MerchVariant{
+		SKU:           req.SKU,
+		Size:          req.Size,
+		Color:         req.Color,
+		PriceOverride: req.PriceOverride,
+		Stock:         req.Stock,
+	})
+	if err == service.ErrMerchNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merch item not found"})
+		return
+	} else if err == service.ErrInvalidVariant {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SKU and a size or color are required, price override must be positive and stock must not be negative"})
+		return
+	} else if err == service.ErrVariantAlreadyExists {
+		c.JSON(http.StatusConflict, gin.H{"error": "Merch variant with this SKU, size and color already exists"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create merch variant"})
+		return
+	}
+	c.JSON(http.StatusCreated, variant)
 }
 
+type UpdateMerchVariantRequest struct {
+	PriceOverride *int  `json:"price_override"`
+	Active        *bool `json:"active"`
+}
+
+func (h *MerchHandler) UpdateMerchVariant(c *gin.Context) {
+	id, variantID, ok := parseVariantPath(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateMerchVariantRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	variant, err := h.merchService.UpdateVariant(c.Request.Context(), id, variantID, model.MerchVariantUpdate{
+		PriceOverride: req.PriceOverride,
+		Active:        req.Active,
+	})
+	h.respondMerchVariant(c, variant, err)
+}
+
+func (h *MerchHandler) RestockMerchVariant(c *gin.Context) {
+	id, variantID, ok := parseVariantPath(c)
+	if !ok {
+		return
+	}
+
+	var req RestockRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	variant, err := h.merchService.RestockVariant(c.Request.Context(), id, variantID, req.Quantity)
+	if err == service.ErrInvalidQuantity {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be positive"})
+		return
+	}
+	h.respondMerchVariant(c, variant, err)
+}
+
+func parseVariantPath(c *gin.Context) (int, int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merch item ID format"})
+		return 0, 0, false
+	}
+	variantID, err := strconv.Atoi(c.Param("variant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merch variant ID format"})
+		return 0, 0, false
+	}
+	return id, variantID, true
+}
+
+func (h *MerchHandler) respondMerchVariant(c *gin.Context, variant model.MerchVariant, err error) {
+	if err == service.ErrVariantNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merch variant not found"})
+		return
+	} else if err == service.ErrInvalidVariant {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Price override must not be negative"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update merch variant"})
+		return
+	}
+	c.JSON(http.StatusOK, variant)
+}
+
+func (h *MerchHandler) respondMerchItem(c *gin.Context, item model.Merch, err error) {
+	if err == service.ErrMerchNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merch item not found"})
+		return
+	} else if err == service.ErrInvalidMerchItem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required and price must be positive"})
+		return
+	} else if err == service.ErrMerchAlreadyExists {
+		c.JSON(http.StatusConflict, gin.H{"error": "Merch item with this name already exists"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update merch item"})
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
 
 func (h *MerchHandler) ListPurchasesByUserID(c *gin.Context) {
- userIDStr := c.Param("user_id")
- if userIDStr == "" {
-  c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
-  return
- }
+	userIDStr := c.Param("user_id")
+	if userIDStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+		return
+	}
 
- userID, err := strconv.Atoi(userIDStr)
- if err != nil {
-  c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID format"})
-  return
- }
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID format"})
+		return
+	}
 
- purchases, err := h.merchService.ListPurchases(c.Request.Context(), userID)
- if err != nil {
-  c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list purchases"})
-  return
- }
- c.JSON(http.StatusOK, purchases)
+	h.listPurchases(c, userID)
 }
 
+func (h *MerchHandler) RefundPurchase(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase ID format"})
+		return
+	}
+
+	purchase, err := h.merchService.RefundPurchase(c.Request.Context(), id)
+	if err == service.ErrPurchaseNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase not found"})
+		return
+	} else if err == service.ErrAlreadyRefunded {
+		c.JSON(http.StatusConflict, gin.H{"error": "Purchase is already refunded"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refund purchase"})
+		return
+	}
+
+	c.JSON(http.StatusOK, purchase)
+}
+
+type OrderStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+}
+
+func (h *MerchHandler) ListOrders(c *gin.Context) {
+	status := c.DefaultQuery("status", model.OrderStatusPlaced)
+
+	orders, err := h.merchService.ListOrders(c.Request.Context(), status)
+	if err == service.ErrInvalidOrderStatus {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order status"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"orders": orders})
+}
+
+func (h *MerchHandler) TransitionOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase ID format"})
+		return
+	}
+
+	var req OrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	purchase, err := h.merchService.TransitionOrder(c.Request.Context(), id, req.Status)
+	if err == service.ErrInvalidOrderStatus {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order status"})
+		return
+	} else if err == service.ErrPurchaseNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase not found"})
+		return
+	} else if err == service.ErrInvalidOrderTransition {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order cannot move to this status"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, purchase)
+}
 
 func (h *MerchHandler) CreatePurchaseForUser(c *gin.Context) {
 	userIDStr := c.Param("user_id")
 	itemName := c.Param("item_name")
-   
+
 	if userIDStr == "" || itemName == "" {
-	 c.JSON(http.StatusBadRequest, gin.H{"error": "User ID and Item Name are required"})
-	 return
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID and Item Name are required"})
+		return
 	}
-   
+
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
-	 c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID format"})
-	 return
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID format"})
+		return
 	}
-   
-	err = h.merchService.PurchaseMerch(c.Request.Context(), userID, itemName)
-	if err != nil {
-	 c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create purchase"})
-	 return
+
+	err = h.merchService.PurchaseMerch(c.Request.Context(), userID, itemName, c.Query("sku"), c.Query("promo_code"))
+	if err == service.ErrInsufficientFunds {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient funds"})
+		return
+	} else if err == service.ErrMerchNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merch item not found"})
+		return
+	} else if err == service.ErrVariantNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merch variant not found"})
+		return
+	} else if err == service.ErrVariantRequired {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Merch item is sold in variants, sku is required"})
+		return
+	} else if err == service.ErrOutOfStock {
+		c.JSON(http.StatusConflict, gin.H{"error": "Merch item is out of stock"})
+		return
+	} else if respondPromoCodeError(c, err) {
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create purchase"})
+		return
 	}
 	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Purchase created successfully"})
-   }
-   
-   type PurchaseHistoryResponse struct {
-	Purchases []model.Purchase `json:"purchases"`
-   }
-   
-   
\ No newline at end of file
+}
+
+// respondPromoCodeError answers 422 if err explains why the entered promo code cannot be used.
+func respondPromoCodeError(c *gin.Context, err error) bool {
+	var message string
+	switch err {
+	case service.ErrPromoCodeInvalid:
+		message = "Promo code is not valid"
+	case service.ErrPromoCodeNotApplicable:
+		message = "Promo code does not apply to this item, or an automatic promotion already gives a larger discount"
+	case service.ErrPromoCodeExhausted:
+		message = "Promo code usage limit reached"
+	default:
+		return false
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": message})
+	return true
+}
+
+type PurchaseHistoryResponse struct {
+	Purchases  []model.Purchase `json:"purchases"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
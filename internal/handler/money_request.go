@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/service"
+)
+
+type MoneyRequestHandler struct {
+	moneyRequestService *service.MoneyRequestService
+}
+
+func NewMoneyRequestHandler(moneyRequestService *service.MoneyRequestService) *MoneyRequestHandler {
+	return &MoneyRequestHandler{moneyRequestService: moneyRequestService}
+}
+
+type CreateMoneyRequestRequest struct {
+	PayerID int    `json:"payer_id"`
+	Amount  int    `json:"amount"`
+	Memo    string `json:"memo"`
+}
+
+func (h *MoneyRequestHandler) Create(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req CreateMoneyRequestRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	mr, err := h.moneyRequestService.Create(c.Request.Context(), int(userID.(float64)), req.PayerID, req.Amount, req.Memo)
+	if err == service.ErrUserNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payer not found"})
+		return
+	} else if err == service.ErrSelfTransfer {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot request coins from yourself"})
+		return
+	} else if err == service.ErrInvalidAmount || err == service.ErrInvalidMemo {
+		c.JSON(http.StatusBadRequest, gin.H{"error": transferErrorMessage(err)})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create money request"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, mr)
+}
+
+func (h *MoneyRequestHandler) ListIncoming(c *gin.Context) {
+	h.list(c, h.moneyRequestService.ListIncoming)
+}
+
+func (h *MoneyRequestHandler) ListOutgoing(c *gin.Context) {
+	h.list(c, h.moneyRequestService.ListOutgoing)
+}
+
+func (h *MoneyRequestHandler) list(c *gin.Context, list func(ctx context.Context, userID int, status string) ([]model.MoneyRequest, error)) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	requests, err := list(c.Request.Context(), int(userID.(float64)), c.Query("status"))
+	if err == service.ErrInvalidStatus {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list money requests"})
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+func (h *MoneyRequestHandler) Accept(c *gin.Context) {
+	h.resolve(c, h.moneyRequestService.Accept)
+}
+
+func (h *MoneyRequestHandler) Decline(c *gin.Context) {
+	h.resolve(c, h.moneyRequestService.Decline)
+}
+
+func (h *MoneyRequestHandler) Cancel(c *gin.Context) {
+	h.resolve(c, h.moneyRequestService.Cancel)
+}
+
+func (h *MoneyRequestHandler) resolve(c *gin.Context, resolve func(ctx context.Context, userID int, id int) (*model.MoneyRequest, error)) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid money request ID format"})
+		return
+	}
+
+	mr, err := resolve(c.Request.Context(), int(userID.(float64)), id)
+	if err == service.ErrMoneyRequestNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Money request not found"})
+		return
+	} else if err == service.ErrMoneyRequestExpired {
+		c.JSON(http.StatusConflict, gin.H{"error": "Money request has expired"})
+		return
+	} else if err == service.ErrInvalidTransition {
+		c.JSON(http.StatusConflict, gin.H{"error": "Money request is no longer pending"})
+		return
+	} else if err == service.ErrInsufficientFunds {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient funds"})
+		return
+	} else if respondLimitExceeded(c, err) {
+		return
+	} else if err == service.ErrUserNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Requester not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update money request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mr)
+}
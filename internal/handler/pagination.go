@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/service"
+)
+
+var errInvalidQuery = errors.New("invalid query parameter")
+
+func parsePage(c *gin.Context) (model.Page, error) {
+	var page model.Page
+
+	cursor, err := service.DecodeCursor(c.Query("cursor"))
+	if err != nil {
+		return page, err
+	}
+	page.After = cursor
+
+	if page.Limit, err = queryInt(c, "limit"); err != nil {
+		return page, err
+	}
+	return page, nil
+}
+
+func parseHistoryFilter(c *gin.Context) (model.HistoryFilter, error) {
+	var filter model.HistoryFilter
+	var err error
+
+	filter.Direction = c.Query("direction")
+	if filter.Direction != "" && filter.Direction != model.DirectionIncoming && filter.Direction != model.DirectionOutgoing {
+		return filter, errInvalidQuery
+	}
+	filter.Category = c.Query("category")
+	if filter.Category != "" && !model.IsValidCategory(filter.Category) {
+		return filter, errInvalidQuery
+	}
+	if filter.CounterpartyID, err = queryInt(c, "counterparty_id"); err != nil {
+		return filter, err
+	}
+	if filter.MinAmount, err = queryInt(c, "min_amount"); err != nil {
+		return filter, err
+	}
+	if filter.MaxAmount, err = queryInt(c, "max_amount"); err != nil {
+		return filter, err
+	}
+	if filter.From, filter.To, err = queryTimeRange(c); err != nil {
+		return filter, err
+	}
+	if filter.Page, err = parsePage(c); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func parsePurchaseFilter(c *gin.Context) (model.PurchaseFilter, error) {
+	var filter model.PurchaseFilter
+	var err error
+
+	filter.ItemName = c.Query("item_name")
+	filter.Status = c.Query("status")
+	if filter.Status != "" && !model.IsValidOrderStatus(filter.Status) {
+		return filter, errInvalidQuery
+	}
+	if filter.MinPrice, err = queryInt(c, "min_price"); err != nil {
+		return filter, err
+	}
+	if filter.MaxPrice, err = queryInt(c, "max_price"); err != nil {
+		return filter, err
+	}
+	if filter.From, filter.To, err = queryTimeRange(c); err != nil {
+		return filter, err
+	}
+	if filter.Page, err = parsePage(c); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// queryInt returns 0 for a missing parameter and rejects negative values.
+func queryInt(c *gin.Context, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, errInvalidQuery
+	}
+	return n, nil
+}
+
+// queryTimeRange reads the RFC 3339 "from" (inclusive) and "to" (exclusive) parameters.
+func queryTimeRange(c *gin.Context) (*time.Time, *time.Time, error) {
+	var from, to *time.Time
+	if value := c.Query("from"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, nil, errInvalidQuery
+		}
+		from = &t
+	}
+	if value := c.Query("to"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, nil, errInvalidQuery
+		}
+		to = &t
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, nil, errInvalidQuery
+	}
+	return from, to, nil
+}
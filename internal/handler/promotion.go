@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/service"
+)
+
+type PromotionHandler struct {
+	promotionService *service.PromotionService
+}
+
+func NewPromotionHandler(promotionService *service.PromotionService) *PromotionHandler {
+	return &PromotionHandler{promotionService: promotionService}
+}
+
+// CreatePromotionRequest describes a promotion. An empty code makes it apply automatically,
+// and a missing starts_at starts it right away.
+type CreatePromotionRequest struct {
+	Code           string     `json:"code"`
+	Name           string     `json:"name"`
+	DiscountType   string     `json:"discount_type"`
+	DiscountValue  int        `json:"discount_value"`
+	ItemName       string     `json:"item_name"`
+	Category       string     `json:"category"`
+	StartsAt       *time.Time `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at"`
+	MaxUses        *int       `json:"max_uses"`
+	MaxUsesPerUser *int       `json:"max_uses_per_user"`
+	Stackable      bool       `json:"stackable"`
+}
+
+func (h *PromotionHandler) Create(c *gin.Context) {
+	var req CreatePromotionRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	promotion := model.Promotion{
+		Code:           req.Code,
+		Name:           req.Name,
+		DiscountType:   req.DiscountType,
+		DiscountValue:  req.DiscountValue,
+		ItemName:       req.ItemName,
+		Category:       req.Category,
+		EndsAt:         req.EndsAt,
+		MaxUses:        req.MaxUses,
+		MaxUsesPerUser: req.MaxUsesPerUser,
+		Stackable:      req.Stackable,
+	}
+	if req.StartsAt != nil {
+		promotion.StartsAt = *req.StartsAt
+	}
+
+	created, err := h.promotionService.Create(c.Request.Context(), promotion)
+	if err == service.ErrInvalidPromotion {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required, discount must be a positive fixed amount or a percent up to 100, scope is an item or a category, and the window and caps must be valid"})
+		return
+	} else if err == service.ErrPromoCodeAlreadyExists {
+		c.JSON(http.StatusConflict, gin.H{"error": "Promotion with this code already exists"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create promotion"})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func (h *PromotionHandler) List(c *gin.Context) {
+	promotions, err := h.promotionService.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list promotions"})
+		return
+	}
+	c.JSON(http.StatusOK, promotions)
+}
+
+func (h *PromotionHandler) Deactivate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion ID format"})
+		return
+	}
+
+	promotion, err := h.promotionService.Deactivate(c.Request.Context(), id)
+	if err == service.ErrPromotionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate promotion"})
+		return
+	}
+	c.JSON(http.StatusOK, promotion)
+}
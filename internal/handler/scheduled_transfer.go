@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/service"
+)
+
+type ScheduledTransferHandler struct {
+	scheduleService *service.ScheduleService
+}
+
+func NewScheduledTransferHandler(scheduleService *service.ScheduleService) *ScheduledTransferHandler {
+	return &ScheduledTransferHandler{scheduleService: scheduleService}
+}
+
+// CreateScheduledTransferRequest schedules a transfer at RunAt, repeated every IntervalSeconds if it is set.
+// Recurrence is a fixed interval only: there are no cron expressions or calendar rules, so
+// "monthly on the 1st" cannot be expressed and a 30-day interval drifts against the calendar.
+type CreateScheduledTransferRequest struct {
+	ReceiverID      int       `json:"receiver_id"`
+	Amount          int       `json:"amount"`
+	Memo            string    `json:"memo"`
+	Category        string    `json:"category"`
+	RunAt           time.Time `json:"run_at"`
+	IntervalSeconds int       `json:"interval_seconds"`
+}
+
+func (h *ScheduledTransferHandler) Create(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req CreateScheduledTransferRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	st, err := h.scheduleService.Create(c.Request.Context(), model.ScheduledTransfer{
+		SenderID:        int(userID.(float64)),
+		ReceiverID:      req.ReceiverID,
+		Amount:          req.Amount,
+		Memo:            req.Memo,
+		Category:        req.Category,
+		NextRunAt:       req.RunAt,
+		IntervalSeconds: req.IntervalSeconds,
+	})
+	if err == service.ErrInvalidSchedule {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Run time must be in the future and interval at least " + service.MinScheduleInterval.String()})
+		return
+	} else if err == service.ErrUserNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receiver not found"})
+		return
+	} else if err == service.ErrInvalidAmount || err == service.ErrSelfTransfer || err == service.ErrInvalidMemo || err == service.ErrInvalidCategory {
+		c.JSON(http.StatusBadRequest, gin.H{"error": transferErrorMessage(err)})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule transfer"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, st)
+}
+
+func (h *ScheduledTransferHandler) List(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	transfers, err := h.scheduleService.List(c.Request.Context(), int(userID.(float64)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list scheduled transfers"})
+		return
+	}
+	c.JSON(http.StatusOK, transfers)
+}
+
+func (h *ScheduledTransferHandler) Pause(c *gin.Context) {
+	h.changeStatus(c, h.scheduleService.Pause)
+}
+
+func (h *ScheduledTransferHandler) Resume(c *gin.Context) {
+	h.changeStatus(c, h.scheduleService.Resume)
+}
+
+func (h *ScheduledTransferHandler) Cancel(c *gin.Context) {
+	h.changeStatus(c, h.scheduleService.Cancel)
+}
+
+func (h *ScheduledTransferHandler) changeStatus(c *gin.Context, change func(ctx context.Context, id, senderID int) (model.ScheduledTransfer, error)) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled transfer ID format"})
+		return
+	}
+
+	st, err := change(c.Request.Context(), id, int(userID.(float64)))
+	if err == service.ErrScheduledTransferNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled transfer not found"})
+		return
+	} else if err == service.ErrScheduleStatusConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheduled transfer cannot change to this status"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update scheduled transfer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, st)
+}
+
+func (h *ScheduledTransferHandler) ListRuns(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled transfer ID format"})
+		return
+	}
+
+	runs, err := h.scheduleService.ListRuns(c.Request.Context(), id, int(userID.(float64)))
+	if err == service.ErrScheduledTransferNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled transfer not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list scheduled transfer runs"})
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/service"
+)
+
+type TransferLimitHandler struct {
+	limitService *service.TransferLimitService
+}
+
+func NewTransferLimitHandler(limitService *service.TransferLimitService) *TransferLimitHandler {
+	return &TransferLimitHandler{limitService: limitService}
+}
+
+func (h *TransferLimitHandler) List(c *gin.Context) {
+	limits, err := h.limitService.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list transfer limits"})
+		return
+	}
+	c.JSON(http.StatusOK, limits)
+}
+
+// UpdateTransferLimitsRequest replaces all limits of a scope or user. An omitted or null limit
+// is inherited: a user inherits from their role and a role from the global scope, where null
+// means no limit. 0 means no limit at any level, so it lifts an inherited limit.
+type UpdateTransferLimitsRequest struct {
+	MaxPerTransfer  *int `json:"max_per_transfer"`
+	MaxDailyAmount  *int `json:"max_daily_amount"`
+	MaxWeeklyAmount *int `json:"max_weekly_amount"`
+	MaxDailyCount   *int `json:"max_daily_count"`
+}
+
+func (h *TransferLimitHandler) Update(c *gin.Context) {
+	var req UpdateTransferLimitsRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	limits, err := h.limitService.Update(c.Request.Context(), model.TransferLimits{
+		Scope:           c.Param("scope"),
+		MaxPerTransfer:  req.MaxPerTransfer,
+		MaxDailyAmount:  req.MaxDailyAmount,
+		MaxWeeklyAmount: req.MaxWeeklyAmount,
+		MaxDailyCount:   req.MaxDailyCount,
+	})
+	if err == service.ErrInvalidLimitScope {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Scope must be global or a role"})
+		return
+	} else if err == service.ErrInvalidLimits {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limits must be positive, or 0 for no limit"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transfer limits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, limits)
+}
+
+func (h *TransferLimitHandler) UpdateForUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	var req UpdateTransferLimitsRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	limits, err := h.limitService.UpdateForUser(c.Request.Context(), model.TransferLimits{
+		UserID:          userID,
+		MaxPerTransfer:  req.MaxPerTransfer,
+		MaxDailyAmount:  req.MaxDailyAmount,
+		MaxWeeklyAmount: req.MaxWeeklyAmount,
+		MaxDailyCount:   req.MaxDailyCount,
+	})
+	if err == service.ErrInvalidLimits {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limits must be positive, or 0 for no limit"})
+		return
+	} else if err == service.ErrUserNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transfer limits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, limits)
+}
+
+func (h *TransferLimitHandler) DeleteForUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	err = h.limitService.DeleteForUser(c.Request.Context(), userID)
+	if err == service.ErrTransferLimitsNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User has no transfer limits of their own"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete transfer limits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
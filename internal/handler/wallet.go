@@ -1,10 +1,14 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/service"
 )
 
@@ -17,8 +21,10 @@ func NewWalletHandler(walletService *service.WalletService) *WalletHandler {
 }
 
 type TransferRequest struct {
-	ReceiverID int `json:"receiver_id"`
-	Amount     int `json:"amount"`
+	ReceiverID int    `json:"receiver_id"`
+	Amount     int    `json:"amount"`
+	Memo       string `json:"memo"`
+	Category   string `json:"category"`
 }
 
 func (h *WalletHandler) Transfer(c *gin.Context) {
@@ -44,19 +50,215 @@ func (h *WalletHandler) Transfer(c *gin.Context) {
 		return
 	}
 
-	err := h.walletService.Transfer(c.Request.Context(), int(senderID.(float64)), req.ReceiverID, req.Amount)
+	response := gin.H{"status": "success", "message": "Coins transferred successfully"}
+	idem, err := newIdempotencyRecord(c, int(senderID.(float64)), req, http.StatusOK, response)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Idempotency-Key header"})
+		return
+	}
+
+	record, err := h.walletService.TransferIdempotent(c.Request.Context(), idem, model.Transaction{
+		SenderID:   int(senderID.(float64)),
+		ReceiverID: req.ReceiverID,
+		Amount:     req.Amount,
+		Memo:       req.Memo,
+		Category:   req.Category,
+	})
 	if err == service.ErrInsufficientFunds {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient funds"})
 		return
 	} else if err == service.ErrInvalidAmount {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer amount"})
 		return
+	} else if err == service.ErrInvalidMemo {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Memo is too long"})
+		return
+	} else if err == service.ErrInvalidCategory {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer category"})
+		return
+	} else if respondLimitExceeded(c, err) {
+		return
+	} else if err == service.ErrUserNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receiver not found"})
+		return
+	} else if err == service.ErrIdempotencyKeyReused {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency key was already used with a different request"})
+		return
 	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer coins"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Coins transferred successfully"})
+	if record != nil {
+		respondIdempotent(c, record)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+type BatchTransferRequest struct {
+	Transfers []TransferRequest `json:"transfers"`
+}
+
+type BatchTransferResult struct {
+	Index      int    `json:"index"`
+	ReceiverID int    `json:"receiver_id"`
+	Amount     int    `json:"amount"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+type BatchTransferResponse struct {
+	Status  string                `json:"status"`
+	Results []BatchTransferResult `json:"results"`
+}
+
+func (h *WalletHandler) TransferBatch(c *gin.Context) {
+	senderID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req BatchTransferRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	transfers := make([]model.Transaction, len(req.Transfers))
+	response := BatchTransferResponse{Status: "success", Results: make([]BatchTransferResult, len(req.Transfers))}
+	for i, item := range req.Transfers {
+		transfers[i] = model.Transaction{
+			ReceiverID: item.ReceiverID,
+			Amount:     item.Amount,
+			Memo:       item.Memo,
+			Category:   item.Category,
+		}
+		response.Results[i] = BatchTransferResult{Index: i, ReceiverID: item.ReceiverID, Amount: item.Amount, Status: "transferred"}
+	}
+
+	idem, err := newIdempotencyRecord(c, int(senderID.(float64)), req, http.StatusOK, response)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Idempotency-Key header"})
+		return
+	}
+
+	record, itemErrs, err := h.walletService.TransferBatch(c.Request.Context(), idem, int(senderID.(float64)), transfers)
+	if err == service.ErrBatchRejected {
+		response.Status = "rejected"
+		for i, itemErr := range itemErrs {
+			if itemErr != nil {
+				response.Results[i].Status = "invalid"
+				response.Results[i].Error = transferErrorMessage(itemErr)
+			} else {
+				response.Results[i].Status = "not_applied"
+			}
+		}
+		c.JSON(http.StatusUnprocessableEntity, response)
+		return
+	} else if err == service.ErrInvalidBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Batch must contain from 1 to %d transfers", service.MaxBatchSize)})
+		return
+	} else if err == service.ErrInsufficientFunds {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient funds"})
+		return
+	} else if respondLimitExceeded(c, err) {
+		return
+	} else if err == service.ErrIdempotencyKeyReused {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency key was already used with a different request"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer coins"})
+		return
+	}
+
+	if record != nil {
+		respondIdempotent(c, record)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// respondLimitExceeded answers 403 with the code of the broken limit if err is a limit violation.
+func respondLimitExceeded(c *gin.Context, err error) bool {
+	var limitErr *service.LimitExceededError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "Transfer limit exceeded", "code": limitErr.Limit, "limit": limitErr.Max})
+	return true
+}
+
+func transferErrorMessage(err error) string {
+	switch err {
+	case service.ErrInvalidAmount:
+		return "Amount must be positive"
+	case service.ErrSelfTransfer:
+		return "Cannot transfer coins to yourself"
+	case service.ErrInvalidMemo:
+		return "Memo is too long"
+	case service.ErrInvalidCategory:
+		return "Invalid transfer category"
+	case service.ErrUserNotFound:
+		return "Receiver not found"
+	default:
+		return "Invalid transfer"
+	}
+}
+
+// CancelTransfer lets the sender reverse their own recent transfer.
+func (h *WalletHandler) CancelTransfer(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID format"})
+		return
+	}
+
+	reversal, err := h.walletService.CancelTransfer(c.Request.Context(), int(userID.(float64)), id)
+	h.respondReversal(c, reversal, err)
+}
+
+// ReverseTransfer reverses any transfer on behalf of an admin.
+func (h *WalletHandler) ReverseTransfer(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID format"})
+		return
+	}
+
+	reversal, err := h.walletService.ReverseTransfer(c.Request.Context(), id)
+	h.respondReversal(c, reversal, err)
+}
+
+func (h *WalletHandler) respondReversal(c *gin.Context, reversal *model.Transaction, err error) {
+	if err == service.ErrTransactionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	} else if err == service.ErrAlreadyReversed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Transaction is already reversed"})
+		return
+	} else if err == service.ErrNotReversible {
+		c.JSON(http.StatusConflict, gin.H{"error": "A reversal cannot be reversed"})
+		return
+	} else if err == service.ErrReversalWindowExpired {
+		c.JSON(http.StatusConflict, gin.H{"error": "Transfer can no longer be cancelled"})
+		return
+	} else if err == service.ErrInsufficientFunds {
+		c.JSON(http.StatusConflict, gin.H{"error": "Receiver no longer has enough coins"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reverse transfer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reversal)
 }
 
 func (h *WalletHandler) GetWallet(c *gin.Context) {
@@ -82,11 +284,57 @@ func (h *WalletHandler) GetWalletHistory(c *gin.Context) {
 		return
 	}
 
-	history, err := h.walletService.GetWalletHistory(c.Request.Context(), int(userID.(float64)))
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters"})
+		return
+	}
+
+	history, nextCursor, err := h.walletService.GetWalletHistory(c.Request.Context(), int(userID.(float64)), filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get wallet history"})
 		return
 	}
 
-	c.JSON(http.StatusOK, history)
+	c.JSON(http.StatusOK, WalletHistoryResponse{History: history, NextCursor: nextCursor})
+}
+
+type WalletHistoryResponse struct {
+	History    []model.WalletHistoryEntry `json:"history"`
+	NextCursor string                     `json:"next_cursor,omitempty"`
+}
+
+type AdjustBalanceRequest struct {
+	Amount int `json:"amount"`
+}
+
+func (h *WalletHandler) AdjustBalance(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	var req AdjustBalanceRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	user, err := h.walletService.AdjustBalance(c.Request.Context(), userID, req.Amount)
+	if err == service.ErrInvalidAmount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must not be zero"})
+		return
+	} else if err == service.ErrUserNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err == service.ErrInsufficientFunds {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Balance cannot become negative"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to adjust balance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
 }
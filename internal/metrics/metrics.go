@@ -0,0 +1,142 @@
+// Package metrics holds the Prometheus collectors of the service and the helpers
+// the other layers use to record business events.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "merch"
+
+// Operations that can be rejected for insufficient funds.
+const (
+	OperationTransfer = "transfer"
+	OperationPurchase = "purchase"
+	OperationHold     = "hold"
+)
+
+// Reasons of a failed login.
+const (
+	LoginUnknownUser   = "unknown_user"
+	LoginWrongPassword = "wrong_password"
+	LoginLocked        = "locked"
+)
+
+// Registry holds every collector of the service; it is served by Handler.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time spent handling HTTP requests, by method and route template.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	httpRequestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests being handled, by method and route template.",
+	}, []string{"method", "route"})
+
+	transfers = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_total",
+		Help:      "Coin transfers completed between users.",
+	})
+
+	coinsTransferred = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coins_transferred_total",
+		Help:      "Coins moved by completed transfers.",
+	})
+
+	purchases = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "purchases_total",
+		Help:      "Merch purchases completed, by item.",
+	}, []string{"item"})
+
+	coinsSpent = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coins_spent_total",
+		Help:      "Coins paid for merch.",
+	})
+
+	insufficientFunds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "insufficient_funds_total",
+		Help:      "Operations rejected because the user did not have enough coins, by operation.",
+	}, []string{"operation"})
+
+	failedLogins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "failed_logins_total",
+		Help:      "Rejected login attempts, by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		httpRequestsInFlight,
+		transfers,
+		coinsTransferred,
+		purchases,
+		coinsSpent,
+		insufficientFunds,
+		failedLogins,
+	)
+}
+
+// RegisterDB exports the connection pool statistics of db as go_sql_* gauges labelled with name.
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves Registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// RequestStarted counts a request in flight and returns a func to call once it is handled.
+func RequestStarted(method, route string) func(status string, seconds float64) {
+	inFlight := httpRequestsInFlight.WithLabelValues(method, route)
+	inFlight.Inc()
+	return func(status string, seconds float64) {
+		inFlight.Dec()
+		httpRequests.WithLabelValues(method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(method, route).Observe(seconds)
+	}
+}
+
+// TransferCompleted records a committed transfer of amount coins.
+func TransferCompleted(amount int) {
+	transfers.Inc()
+	coinsTransferred.Add(float64(amount))
+}
+
+// PurchaseCompleted records a committed purchase of item for price coins.
+func PurchaseCompleted(item string, price int) {
+	purchases.WithLabelValues(item).Inc()
+	coinsSpent.Add(float64(price))
+}
+
+func InsufficientFunds(operation string) {
+	insufficientFunds.WithLabelValues(operation).Inc()
+}
+
+func FailedLogin(reason string) {
+	failedLogins.WithLabelValues(reason).Inc()
+}
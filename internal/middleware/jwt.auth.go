@@ -7,9 +7,14 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/logging"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/service"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/tracing"
 )
 
-func JWTAuthMiddleware(jwtSecret string) gin.HandlerFunc {
+func JWTAuthMiddleware(jwtSecret string, authService *service.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -37,7 +42,32 @@ func JWTAuthMiddleware(jwtSecret string) gin.HandlerFunc {
 				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
 				return
 			}
+			sessionID, ok := claims["sid"].(string)
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid session in token"})
+				return
+			}
+
+			active, err := authService.IsSessionActive(c.Request.Context(), sessionID, int(userIDFloat))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check session"})
+				return
+			}
+			if !active {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+				return
+			}
+
+			role, ok := claims["role"].(string)
+			if !ok {
+				role = model.RoleUser
+			}
+
+			tracing.SetUserID(c.Request.Context(), int(userIDFloat))
+			c.Request = c.Request.WithContext(logging.WithUserID(c.Request.Context(), int(userIDFloat)))
 			c.Set("userID", userIDFloat)
+			c.Set("sessionID", sessionID)
+			c.Set("role", role)
 			c.Next()
 		} else {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/metrics"
+)
+
+// unmatchedRoute labels requests that matched no route, so arbitrary paths do not become label values.
+const unmatchedRoute = "unmatched"
+
+// Metrics records the count, latency and in-flight number of requests per route template.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		started := time.Now()
+		done := metrics.RequestStarted(c.Request.Method, route)
+		c.Next()
+		done(strconv.Itoa(c.Writer.Status()), time.Since(started).Seconds())
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/logging"
+)
+
+func TestIsValidRequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		want      bool
+	}{
+		{"uuid", "3f2c1a9e-8b7d-4c6e-9f10-2a3b4c5d6e7f", true},
+		{"generated", newRequestID(), true},
+		{"punctuation", "req:42/retry#1~", true},
+		{"max length", strings.Repeat("a", maxRequestIDLength), true},
+		{"empty", "", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"space", "req 42", false},
+		{"newline", "req42\nlevel=ERROR msg=forged", false},
+		{"tab", "req\t42", false},
+		{"delete", "req\x7f42", false},
+		{"non-ascii", "запрос-42", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isValidRequestID(tt.requestID); got != tt.want {
+				t.Errorf("isValidRequestID(%q) = %v, want %v", tt.requestID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		header   string
+		wantKept bool
+	}{
+		{"kept", "client-supplied-42", true},
+		{"missing", "", false},
+		{"malformed", "bad id", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inContext string
+			r := gin.New()
+			r.Use(RequestID())
+			r.GET("/", func(c *gin.Context) {
+				inContext = logging.RequestID(c.Request.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			got := w.Header().Get(RequestIDHeader)
+			if tt.wantKept && got != tt.header {
+				t.Errorf("response request ID = %q, want %q", got, tt.header)
+			}
+			if !tt.wantKept && (got == tt.header || len(got) != 32) {
+				t.Errorf("response request ID = %q, want a generated one", got)
+			}
+			if inContext != got {
+				t.Errorf("request ID in context = %q, want %q", inContext, got)
+			}
+		})
+	}
+}
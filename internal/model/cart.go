@@ -0,0 +1,18 @@
+package model
+
+type CartItem struct {
+	ItemID    int    `json:"item_id"`
+	ItemName  string `json:"item_name"`
+	Price     int    `json:"price"`
+	Quantity  int    `json:"quantity"`
+	Subtotal  int    `json:"subtotal"`
+	Available bool   `json:"available"`
+}
+
+// Cart lists the items at their list prices. Promotions are only applied at checkout, whose
+// cart has the Total that was paid and the Discount taken off it.
+type Cart struct {
+	Items    []CartItem `json:"items"`
+	Total    int        `json:"total"`
+	Discount int        `json:"discount,omitempty"`
+}
@@ -0,0 +1,19 @@
+package model
+
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// HealthCheck is the result of one readiness check. Latency is only reported in verbose mode.
+type HealthCheck struct {
+	Status  string `json:"status"`
+	Detail  string `json:"detail,omitempty"`
+	Latency string `json:"latency,omitempty"`
+}
+
+// HealthReport is ok only if every check is.
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+const (
+	HoldStatusActive   = "active"
+	HoldStatusCaptured = "captured"
+	HoldStatusReleased = "released"
+	HoldStatusExpired  = "expired"
+)
+
+// Hold reserves Amount coins of UserID for a merch item until it is captured,
+// released or ExpiresAt passes.
+type Hold struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	ItemID     int        `json:"item_id"`
+	ItemName   string     `json:"item_name"`
+	Amount     int        `json:"amount"`
+	Status     string     `json:"status"`
+	PurchaseID int        `json:"purchase_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+type IdempotencyRecord struct {
+	UserID         int
+	Key            string
+	RequestHash    string
+	ResponseStatus int
+	ResponseBody   []byte
+	CreatedAt      time.Time
+	Replayed       bool
+}
@@ -0,0 +1,65 @@
+package model
+
+import "time"
+
+const (
+	EntryKindTransfer   = "transfer"
+	EntryKindPurchase   = "purchase"
+	EntryKindGrant      = "grant"
+	EntryKindRefund     = "refund"
+	EntryKindAdjustment = "adjustment"
+)
+
+const (
+	AccountInitialGrant    = "initial_grant"
+	AccountMerchRevenue    = "merch_revenue"
+	AccountAdminAdjustment = "admin_adjustment"
+)
+
+// Posting moves Amount into an account (out of it if negative). The account is either
+// a user's wallet (UserID) or a system account (AccountCode).
+type Posting struct {
+	UserID      int
+	AccountCode string
+	Amount      int
+}
+
+type JournalEntry struct {
+	ID          int
+	Kind        string
+	Description string
+	Postings    []Posting
+	CreatedAt   time.Time
+}
+
+// Balanced reports whether the entry moves coins between at least two accounts: it has two or
+// more postings, none of them zero, and they sum to zero.
+func (e JournalEntry) Balanced() bool {
+	sum := 0
+	for _, posting := range e.Postings {
+		if posting.Amount == 0 {
+			return false
+		}
+		sum += posting.Amount
+	}
+	return sum == 0 && len(e.Postings) >= 2
+}
+
+type AccountBalance struct {
+	Code    string `json:"code"`
+	Balance int    `json:"balance"`
+}
+
+type BalanceMismatch struct {
+	UserID        int `json:"user_id"`
+	CachedBalance int `json:"cached_balance"`
+	LedgerBalance int `json:"ledger_balance"`
+}
+
+type LedgerReport struct {
+	Balanced          bool              `json:"balanced"`
+	TotalPostings     int               `json:"total_postings"`
+	UnbalancedEntries []int             `json:"unbalanced_entries"`
+	BalanceMismatches []BalanceMismatch `json:"balance_mismatches"`
+	SystemAccounts    []AccountBalance  `json:"system_accounts"`
+}
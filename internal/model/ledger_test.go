@@ -0,0 +1,27 @@
+package model
+
+import "testing"
+
+func TestJournalEntryBalanced(t *testing.T) {
+	tests := []struct {
+		name     string
+		postings []Posting
+		want     bool
+	}{
+		{"two opposite postings", []Posting{{UserID: 1, Amount: -50}, {UserID: 2, Amount: 50}}, true},
+		{"one debit split over two credits", []Posting{{UserID: 1, Amount: -30}, {UserID: 2, Amount: 10}, {AccountCode: AccountMerchRevenue, Amount: 20}}, true},
+		{"no postings", nil, false},
+		{"single posting", []Posting{{UserID: 1, Amount: 0}}, false},
+		{"does not sum to zero", []Posting{{UserID: 1, Amount: -50}, {UserID: 2, Amount: 40}}, false},
+		{"zero postings that sum to zero", []Posting{{UserID: 1, Amount: 0}, {UserID: 2, Amount: 0}}, false},
+		{"balanced but with a zero posting", []Posting{{UserID: 1, Amount: -5}, {UserID: 2, Amount: 5}, {UserID: 3, Amount: 0}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := JournalEntry{Kind: EntryKindTransfer, Postings: tt.postings}
+			if got := entry.Balanced(); got != tt.want {
+				t.Errorf("Balanced() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,14 +1,70 @@
 package model
 
+import "time"
+
+// Merch.Stock is nil for items without stock tracking.
 type Merch struct {
-	Name  string `json:"name"`
-	Price int    `json:"price"`
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Price       int       `json:"price"`
+	Description string    `json:"description"`
+	Category    string    `json:"category"`
+	Active      bool      `json:"active"`
+	Stock       *int      `json:"stock"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	Variants []MerchVariant `json:"variants,omitempty"`
+}
+
+// MerchVariant is a size or color of a merch item with its own SKU and stock.
+// Price is what the variant costs: PriceOverride if set, the item price otherwise.
+// Stock is nil for variants without stock tracking.
+type MerchVariant struct {
+	ID            int       `json:"id"`
+	MerchID       int       `json:"merch_id"`
+	SKU           string    `json:"sku"`
+	Size          string    `json:"size,omitempty"`
+	Color         string    `json:"color,omitempty"`
+	Price         int       `json:"price"`
+	PriceOverride *int      `json:"price_override"`
+	Stock         *int      `json:"stock"`
+	Active        bool      `json:"active"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
+// Purchase.Price is what the buyer paid: ListPrice less Discount.
 type Purchase struct {
 	ID          int    `json:"id"`
 	UserID      int    `json:"user_id"`
+	ItemID      int    `json:"item_id,omitempty"`
 	ItemName    string `json:"item_name"`
+	SKU         string `json:"sku,omitempty"`
+	ListPrice   int    `json:"list_price"`
+	Discount    int    `json:"discount"`
 	Price       int    `json:"price"`
+	Status      string `json:"status"`
 	PurchasedAt string `json:"purchased_at"`
+	ApprovedAt  string `json:"approved_at,omitempty"`
+	ReadyAt     string `json:"ready_at,omitempty"`
+	DeliveredAt string `json:"delivered_at,omitempty"`
+	CancelledAt string `json:"cancelled_at,omitempty"`
+	RefundedAt  string `json:"refunded_at,omitempty"`
+}
+
+// MerchUpdate holds the fields of a merch item to change; nil fields are left as is.
+type MerchUpdate struct {
+	Name        *string `json:"name"`
+	Price       *int    `json:"price"`
+	Description *string `json:"description"`
+	Category    *string `json:"category"`
+	Active      *bool   `json:"active"`
+}
+
+// MerchVariantUpdate holds the fields of a variant to change; nil fields are left as is.
+// A zero PriceOverride removes the override, so the variant costs as much as its item again.
+type MerchVariantUpdate struct {
+	PriceOverride *int  `json:"price_override"`
+	Active        *bool `json:"active"`
 }
@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+const (
+	MoneyRequestPending   = "pending"
+	MoneyRequestAccepted  = "accepted"
+	MoneyRequestDeclined  = "declined"
+	MoneyRequestExpired   = "expired"
+	MoneyRequestCancelled = "cancelled"
+)
+
+// moneyRequestTransitions lists the statuses each status can move to. Every status
+// other than pending is final.
+var moneyRequestTransitions = map[string][]string{
+	MoneyRequestPending: {MoneyRequestAccepted, MoneyRequestDeclined, MoneyRequestExpired, MoneyRequestCancelled},
+}
+
+func CanTransitionMoneyRequest(from, to string) bool {
+	for _, status := range moneyRequestTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+func IsValidMoneyRequestStatus(status string) bool {
+	switch status {
+	case MoneyRequestPending, MoneyRequestAccepted, MoneyRequestDeclined, MoneyRequestExpired, MoneyRequestCancelled:
+		return true
+	}
+	return false
+}
+
+// MoneyRequest asks PayerID to transfer Amount to RequesterID.
+type MoneyRequest struct {
+	ID            int        `json:"id"`
+	RequesterID   int        `json:"requester_id"`
+	PayerID       int        `json:"payer_id"`
+	Amount        int        `json:"amount"`
+	Memo          string     `json:"memo,omitempty"`
+	Status        string     `json:"status"`
+	TransactionID int        `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+}
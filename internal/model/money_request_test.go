@@ -0,0 +1,28 @@
+package model
+
+import "testing"
+
+func TestCanTransitionMoneyRequest(t *testing.T) {
+	statuses := []string{MoneyRequestPending, MoneyRequestAccepted, MoneyRequestDeclined, MoneyRequestExpired, MoneyRequestCancelled}
+	allowed := map[[2]string]bool{
+		{MoneyRequestPending, MoneyRequestAccepted}:  true,
+		{MoneyRequestPending, MoneyRequestDeclined}:  true,
+		{MoneyRequestPending, MoneyRequestExpired}:   true,
+		{MoneyRequestPending, MoneyRequestCancelled}: true,
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[[2]string{from, to}]
+			if got := CanTransitionMoneyRequest(from, to); got != want {
+				t.Errorf("CanTransitionMoneyRequest(%q, %q) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+
+	for _, status := range []string{"", "paid"} {
+		if CanTransitionMoneyRequest(status, MoneyRequestAccepted) || CanTransitionMoneyRequest(MoneyRequestPending, status) {
+			t.Errorf("transition involving unknown status %q allowed", status)
+		}
+	}
+}
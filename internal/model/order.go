@@ -0,0 +1,35 @@
+package model
+
+// Every purchase is an order that the office team fulfils.
+const (
+	OrderStatusPlaced         = "placed"
+	OrderStatusApproved       = "approved"
+	OrderStatusReadyForPickup = "ready_for_pickup"
+	OrderStatusDelivered      = "delivered"
+	OrderStatusCancelled      = "cancelled"
+)
+
+// orderTransitions lists the statuses each order status can move to. Delivered and
+// cancelled orders are final.
+var orderTransitions = map[string][]string{
+	OrderStatusPlaced:         {OrderStatusApproved, OrderStatusCancelled},
+	OrderStatusApproved:       {OrderStatusReadyForPickup, OrderStatusCancelled},
+	OrderStatusReadyForPickup: {OrderStatusDelivered, OrderStatusCancelled},
+}
+
+func CanTransitionOrder(from, to string) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+func IsValidOrderStatus(status string) bool {
+	switch status {
+	case OrderStatusPlaced, OrderStatusApproved, OrderStatusReadyForPickup, OrderStatusDelivered, OrderStatusCancelled:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// Cursor points at the last row of a page ordered by (created_at, id) descending.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"id"`
+}
+
+type Page struct {
+	After *Cursor
+	Limit int
+}
+
+const (
+	DirectionIncoming = "incoming"
+	DirectionOutgoing = "outgoing"
+)
+
+type HistoryFilter struct {
+	Direction      string
+	CounterpartyID int
+	Category       string
+	MinAmount      int
+	MaxAmount      int
+	From           *time.Time
+	To             *time.Time
+	Page
+}
+
+type PurchaseFilter struct {
+	ItemName string
+	Status   string
+	MinPrice int
+	MaxPrice int
+	From     *time.Time
+	To       *time.Time
+	Page
+}
@@ -0,0 +1,63 @@
+package model
+
+import "time"
+
+const (
+	DiscountTypePercent = "percent"
+	DiscountTypeFixed   = "fixed"
+)
+
+// Promotion discounts purchases of ItemName, of items in Category, or of any item if both are empty.
+// A promotion without a Code applies automatically. Nil caps and EndsAt mean no limit.
+// A stackable promotion combines with other stackable ones; any other is applied on its own.
+type Promotion struct {
+	ID             int        `json:"id"`
+	Code           string     `json:"code,omitempty"`
+	Name           string     `json:"name"`
+	DiscountType   string     `json:"discount_type"`
+	DiscountValue  int        `json:"discount_value"`
+	ItemName       string     `json:"item_name,omitempty"`
+	Category       string     `json:"category,omitempty"`
+	StartsAt       time.Time  `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	MaxUses        *int       `json:"max_uses"`
+	MaxUsesPerUser *int       `json:"max_uses_per_user"`
+	Uses           int        `json:"uses"`
+	Stackable      bool       `json:"stackable"`
+	Active         bool       `json:"active"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Discount returns how many coins the promotion takes off price. It never exceeds price.
+func (p Promotion) Discount(price int) int {
+	discount := p.DiscountValue
+	if p.DiscountType == DiscountTypePercent {
+		discount = price * p.DiscountValue / 100
+	}
+	if discount > price {
+		return price
+	}
+	return discount
+}
+
+// AppliesTo reports whether item is in the scope of the promotion.
+func (p Promotion) AppliesTo(item Merch) bool {
+	if p.ItemName != "" {
+		return p.ItemName == item.Name
+	}
+	if p.Category != "" {
+		return p.Category == item.Category
+	}
+	return true
+}
+
+// InWindow reports whether the promotion runs at t.
+func (p Promotion) InWindow(t time.Time) bool {
+	return !t.Before(p.StartsAt) && (p.EndsAt == nil || t.Before(*p.EndsAt))
+}
+
+// Redemption is one promotion applied to one purchase.
+type Redemption struct {
+	PromotionID int `json:"promotion_id"`
+	Discount    int `json:"discount"`
+}
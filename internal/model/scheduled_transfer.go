@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+const (
+	ScheduleStatusActive    = "active"
+	ScheduleStatusPaused    = "paused"
+	ScheduleStatusCompleted = "completed"
+	ScheduleStatusCancelled = "cancelled"
+)
+
+const (
+	RunStatusRunning   = "running"
+	RunStatusSucceeded = "succeeded"
+	RunStatusFailed    = "failed"
+)
+
+// ScheduledTransfer runs once at NextRunAt, or every IntervalSeconds starting from it.
+// The interval is fixed; calendar-based recurrence is not supported.
+type ScheduledTransfer struct {
+	ID              int        `json:"id"`
+	SenderID        int        `json:"sender_id"`
+	ReceiverID      int        `json:"receiver_id"`
+	Amount          int        `json:"amount"`
+	Memo            string     `json:"memo,omitempty"`
+	Category        string     `json:"category"`
+	IntervalSeconds int        `json:"interval_seconds,omitempty"`
+	Status          string     `json:"status"`
+	NextRunAt       time.Time  `json:"next_run_at"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type ScheduledTransferRun struct {
+	ID                  int        `json:"id"`
+	ScheduledTransferID int        `json:"scheduled_transfer_id"`
+	OccurrenceAt        time.Time  `json:"occurrence_at"`
+	Status              string     `json:"status"`
+	Error               string     `json:"error,omitempty"`
+	StartedAt           time.Time  `json:"started_at"`
+	FinishedAt          *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+type RefreshToken struct {
+	ID        int
+	SessionID string
+	UserID    int
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	Revoked   bool
+}
+
+type TokenPair struct {
+	AccessToken  string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
@@ -2,10 +2,34 @@ package model
 
 import "time"
 
+const (
+	CategoryKudos         = "kudos"
+	CategoryReimbursement = "reimbursement"
+	CategoryGift          = "gift"
+	CategoryOther         = "other"
+)
+
+// MaxMemoLength is the memo limit in characters.
+const MaxMemoLength = 200
+
+func IsValidCategory(category string) bool {
+	switch category {
+	case CategoryKudos, CategoryReimbursement, CategoryGift, CategoryOther:
+		return true
+	}
+	return false
+}
+
+// Transaction is a transfer between users. A reversal is a transaction in the opposite
+// direction with ReversesID set; the original then has ReversedByID set.
 type Transaction struct {
- ID         int       `json:"id"`
- SenderID   int       `json:"sender_id"`
- ReceiverID int       `json:"receiver_id"`
- Amount     int       `json:"amount"`
- CreatedAt  time.Time `json:"created_at"`
+	ID           int       `json:"id"`
+	SenderID     int       `json:"sender_id"`
+	ReceiverID   int       `json:"receiver_id"`
+	Amount       int       `json:"amount"`
+	Memo         string    `json:"memo,omitempty"`
+	Category     string    `json:"category"`
+	ReversesID   int       `json:"reverses_transaction_id,omitempty"`
+	ReversedByID int       `json:"reversed_by_transaction_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
 }
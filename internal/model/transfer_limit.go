@@ -0,0 +1,69 @@
+package model
+
+import "time"
+
+// LimitScopeGlobal is the scope of the limits that apply to every role.
+const LimitScopeGlobal = "global"
+
+const (
+	LimitMaxPerTransfer  = "max_per_transfer"
+	LimitMaxDailyAmount  = "max_daily_amount"
+	LimitMaxWeeklyAmount = "max_weekly_amount"
+	LimitMaxDailyCount   = "max_daily_count"
+)
+
+// LimitUnlimited set as a limit means no limit, even if the level below sets one.
+const LimitUnlimited = 0
+
+func IsValidLimitScope(scope string) bool {
+	return scope == LimitScopeGlobal || IsValidRole(scope)
+}
+
+// TransferLimits caps outgoing transfers; a nil field is not limited. Limits apply to a
+// Scope (global or a role), or to one user if UserID is set.
+type TransferLimits struct {
+	Scope           string    `json:"scope,omitempty"`
+	UserID          int       `json:"user_id,omitempty"`
+	MaxPerTransfer  *int      `json:"max_per_transfer"`
+	MaxDailyAmount  *int      `json:"max_daily_amount"`
+	MaxWeeklyAmount *int      `json:"max_weekly_amount"`
+	MaxDailyCount   *int      `json:"max_daily_count"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Override returns l with every limit set in o replacing the one in l.
+func (l TransferLimits) Override(o TransferLimits) TransferLimits {
+	if o.MaxPerTransfer != nil {
+		l.MaxPerTransfer = o.MaxPerTransfer
+	}
+	if o.MaxDailyAmount != nil {
+		l.MaxDailyAmount = o.MaxDailyAmount
+	}
+	if o.MaxWeeklyAmount != nil {
+		l.MaxWeeklyAmount = o.MaxWeeklyAmount
+	}
+	if o.MaxDailyCount != nil {
+		l.MaxDailyCount = o.MaxDailyCount
+	}
+	return l
+}
+
+// ResolveTransferLimits returns the limits that apply to a user: the user's own limits win
+// over those of their role, which win over the global ones. A nil limit inherits from the
+// level below and LimitUnlimited lifts it.
+func ResolveTransferLimits(global, role, user TransferLimits) TransferLimits {
+	l := global.Override(role).Override(user)
+	for _, limit := range []**int{&l.MaxPerTransfer, &l.MaxDailyAmount, &l.MaxWeeklyAmount, &l.MaxDailyCount} {
+		if *limit != nil && **limit == LimitUnlimited {
+			*limit = nil
+		}
+	}
+	return l
+}
+
+// OutgoingTotals sums a user's outgoing transfers over the rolling limit windows.
+type OutgoingTotals struct {
+	DailyAmount  int
+	WeeklyAmount int
+	DailyCount   int
+}
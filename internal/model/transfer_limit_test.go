@@ -0,0 +1,64 @@
+package model
+
+import "testing"
+
+func limit(n int) *int { return &n }
+
+func TestResolveTransferLimits(t *testing.T) {
+	tests := []struct {
+		name               string
+		global, role, user *int
+		wantPerTransfer    *int
+	}{
+		{"nothing set", nil, nil, nil, nil},
+		{"global only", limit(100), nil, nil, limit(100)},
+		{"role overrides global", limit(100), limit(500), nil, limit(500)},
+		{"role can tighten global", limit(100), limit(50), nil, limit(50)},
+		{"user overrides role", limit(100), limit(500), limit(20), limit(20)},
+		{"user overrides global without role", limit(100), nil, limit(300), limit(300)},
+		{"role lifts global", limit(100), limit(LimitUnlimited), nil, nil},
+		{"user lifts role", limit(100), limit(500), limit(LimitUnlimited), nil},
+		{"user limits where role lifted", limit(100), limit(LimitUnlimited), limit(10), limit(10)},
+		{"unlimited global", limit(LimitUnlimited), nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResolveTransferLimits(
+				TransferLimits{Scope: LimitScopeGlobal, MaxPerTransfer: tt.global},
+				TransferLimits{Scope: RoleUser, MaxPerTransfer: tt.role},
+				TransferLimits{UserID: 1, MaxPerTransfer: tt.user},
+			)
+			switch {
+			case tt.wantPerTransfer == nil && got.MaxPerTransfer != nil:
+				t.Errorf("MaxPerTransfer = %d, want no limit", *got.MaxPerTransfer)
+			case tt.wantPerTransfer != nil && got.MaxPerTransfer == nil:
+				t.Errorf("MaxPerTransfer = no limit, want %d", *tt.wantPerTransfer)
+			case tt.wantPerTransfer != nil && *got.MaxPerTransfer != *tt.wantPerTransfer:
+				t.Errorf("MaxPerTransfer = %d, want %d", *got.MaxPerTransfer, *tt.wantPerTransfer)
+			}
+			if got.MaxDailyAmount != nil || got.MaxWeeklyAmount != nil || got.MaxDailyCount != nil {
+				t.Errorf("limits that no level sets = %+v, want none", got)
+			}
+		})
+	}
+}
+
+func TestResolveTransferLimitsPerLimit(t *testing.T) {
+	got := ResolveTransferLimits(
+		TransferLimits{MaxPerTransfer: limit(100), MaxDailyAmount: limit(1000), MaxWeeklyAmount: limit(5000)},
+		TransferLimits{MaxDailyAmount: limit(LimitUnlimited), MaxDailyCount: limit(10)},
+		TransferLimits{MaxWeeklyAmount: limit(2000)},
+	)
+	if got.MaxPerTransfer == nil || *got.MaxPerTransfer != 100 {
+		t.Errorf("MaxPerTransfer = %v, want 100 from global", got.MaxPerTransfer)
+	}
+	if got.MaxDailyAmount != nil {
+		t.Errorf("MaxDailyAmount = %d, want no limit lifted by role", *got.MaxDailyAmount)
+	}
+	if got.MaxWeeklyAmount == nil || *got.MaxWeeklyAmount != 2000 {
+		t.Errorf("MaxWeeklyAmount = %v, want 2000 from user", got.MaxWeeklyAmount)
+	}
+	if got.MaxDailyCount == nil || *got.MaxDailyCount != 10 {
+		t.Errorf("MaxDailyCount = %v, want 10 from role", got.MaxDailyCount)
+	}
+}
@@ -1,18 +1,51 @@
 package model
 
+import "time"
+
+const (
+	RoleUser    = "user"
+	RoleAdmin   = "admin"
+	RoleAuditor = "auditor"
+)
+
+func IsValidRole(role string) bool {
+	return role == RoleUser || role == RoleAdmin || role == RoleAuditor
+}
+
 type User struct {
-	ID    int `json:"id"`
-	Coins int `json:"coins"`
+	ID       int    `json:"id"`
+	Coins    int    `json:"coins"`
+	Username string `json:"username,omitempty"`
+	Role     string `json:"role"`
+}
+
+// Credentials are never serialized; they are only used by the auth service.
+type Credentials struct {
+	UserID              int
+	Username            string
+	PasswordHash        string
+	FailedLoginAttempts int
+	LockedUntil         *time.Time
 }
 
+// Wallet reports the ledger balance in Coins; HeldCoins of it are reserved by holds
+// and cannot be spent until the holds are released or expire.
 type Wallet struct {
 	Coins              int                  `json:"coins"`
+	HeldCoins          int                  `json:"held_coins"`
+	AvailableCoins     int                  `json:"available_coins"`
 	TransactionHistory []WalletHistoryEntry `json:"transaction_history"`
+	NextCursor         string               `json:"next_cursor,omitempty"`
 }
 
 type WalletHistoryEntry struct {
-	TransactionType string `json:"transaction_type"` 
+	TransactionID   int    `json:"transaction_id"`
+	TransactionType string `json:"transaction_type"`
 	CounterpartyID  int    `json:"counterparty_id"`
 	Amount          int    `json:"amount"`
-	CreatedAt       string `json:"created_at"` 
+	Memo            string `json:"memo,omitempty"`
+	Category        string `json:"category"`
+	ReversesID      int    `json:"reverses_transaction_id,omitempty"`
+	ReversedByID    int    `json:"reversed_by_transaction_id,omitempty"`
+	CreatedAt       string `json:"created_at"`
 }
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+)
+
+var ErrCartItemNotFound = errors.New("cart item not found")
+
+type CartRepository struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+func NewCartRepository(db *sql.DB) *CartRepository {
+	return &CartRepository{db: db}
+}
+
+func NewCartRepositoryWithTx(tx *sql.Tx) *CartRepository {
+	return &CartRepository{tx: tx}
+}
+
+// AddItem adds quantity units of the item to the cart, summing with units already there.
+func (r *CartRepository) AddItem(ctx context.Context, userID, itemID int, quantity int) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		`INSERT INTO cart_items (user_id, item_id, quantity) VALUES ($1, $2, $3)
+   ON CONFLICT (user_id, item_id) DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity`,
+		userID, itemID, quantity,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add cart item: %w", err)
+	}
+	return nil
+}
+
+func (r *CartRepository) RemoveItem(ctx context.Context, userID, itemID int) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	res, err := execContext(ctx,
+		"DELETE FROM cart_items WHERE user_id = $1 AND item_id = $2", userID, itemID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove cart item: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows count after delete: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrCartItemNotFound
+	}
+	return nil
+}
+
+// ListItems returns the cart priced with the current catalog prices, ordered by item ID.
+func (r *CartRepository) ListItems(ctx context.Context, userID int) ([]model.CartItem, error) {
+	return r.listItems(ctx, userID, "")
+}
+
+// ListItemsForUpdate is ListItems that also locks the cart rows until the transaction ends,
+// so concurrent checkouts of the same cart are serialized.
+func (r *CartRepository) ListItemsForUpdate(ctx context.Context, userID int) ([]model.CartItem, error) {
+	return r.listItems(ctx, userID, " FOR UPDATE OF c")
+}
+
+func (r *CartRepository) listItems(ctx context.Context, userID int, lockClause string) ([]model.CartItem, error) {
+	var queryContext func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	if r.tx != nil {
+		queryContext = r.tx.QueryContext
+	} else {
+		queryContext = r.db.QueryContext
+	}
+
+	rows, err := queryContext(ctx,
+		`SELECT m.id, m.name, m.price, c.quantity, m.active
+   FROM cart_items c
+   JOIN merch_items m ON m.id = c.item_id
+   WHERE c.user_id = $1
+   ORDER BY m.id`+lockClause, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cart items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []model.CartItem{}
+	for rows.Next() {
+		var item model.CartItem
+		if err := rows.Scan(&item.ItemID, &item.ItemName, &item.Price, &item.Quantity, &item.Available); err != nil {
+			return nil, fmt.Errorf("failed to scan cart item: %w", err)
+		}
+		item.Subtotal = item.Price * item.Quantity
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cart item rows: %w", err)
+	}
+
+	return items, nil
+}
+
+func (r *CartRepository) Clear(ctx context.Context, userID int) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx, "DELETE FROM cart_items WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to clear cart: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+)
+
+var ErrHoldNotFound = errors.New("hold not found")
+
+const holdColumns = "h.id, h.user_id, h.item_id, m.name, h.amount, h.status, h.purchase_id, h.created_at, h.expires_at, h.resolved_at"
+
+// heldCoinsSQL returns a subquery summing the coins held for the user bound to param.
+// Holds past their expiry no longer count, even before they are marked expired.
+func heldCoinsSQL(param string) string {
+	return `(SELECT COALESCE(SUM(amount), 0) FROM holds
+   WHERE user_id = ` + param + ` AND status = 'active' AND expires_at > CURRENT_TIMESTAMP)`
+}
+
+type HoldRepository struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+func NewHoldRepository(db *sql.DB) *HoldRepository {
+	return &HoldRepository{db: db}
+}
+
+func NewHoldRepositoryWithTx(tx *sql.Tx) *HoldRepository {
+	return &HoldRepository{tx: tx}
+}
+
+func scanHold(row interface{ Scan(...interface{}) error }) (model.Hold, error) {
+	var hold model.Hold
+	var purchaseID sql.NullInt64
+	var resolvedAt sql.NullTime
+	err := row.Scan(&hold.ID, &hold.UserID, &hold.ItemID, &hold.ItemName, &hold.Amount, &hold.Status, &purchaseID,
+		&hold.CreatedAt, &hold.ExpiresAt, &resolvedAt)
+	hold.PurchaseID = int(purchaseID.Int64)
+	if resolvedAt.Valid {
+		hold.ResolvedAt = &resolvedAt.Time
+	}
+	return hold, err
+}
+
+func (r *HoldRepository) Create(ctx context.Context, hold model.Hold) (model.Hold, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	created, err := scanHold(queryRow(ctx,
+		`WITH h AS (
+   INSERT INTO holds (user_id, item_id, amount, expires_at) VALUES ($1, $2, $3, $4)
+   RETURNING *
+   )
+   SELECT `+holdColumns+` FROM h JOIN merch_items m ON m.id = h.item_id`,
+		hold.UserID, hold.ItemID, hold.Amount, hold.ExpiresAt,
+	))
+	if err != nil {
+		return model.Hold{}, fmt.Errorf("failed to create hold: %w", err)
+	}
+	return created, nil
+}
+
+// GetForUpdate locks the hold row until the transaction ends.
+func (r *HoldRepository) GetForUpdate(ctx context.Context, id int) (model.Hold, error) {
+	if r.tx == nil {
+		return model.Hold{}, errors.New("row lock requires a transaction")
+	}
+
+	hold, err := scanHold(r.tx.QueryRowContext(ctx,
+		"SELECT "+holdColumns+" FROM holds h JOIN merch_items m ON m.id = h.item_id WHERE h.id = $1 FOR UPDATE OF h", id,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Hold{}, ErrHoldNotFound
+		}
+		return model.Hold{}, fmt.Errorf("failed to lock hold: %w", err)
+	}
+	return hold, nil
+}
+
+// ListByUser returns the user's holds, newest first.
+func (r *HoldRepository) ListByUser(ctx context.Context, userID int) ([]model.Hold, error) {
+	var queryContext func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	if r.tx != nil {
+		queryContext = r.tx.QueryContext
+	} else {
+		queryContext = r.db.QueryContext
+	}
+
+	rows, err := queryContext(ctx,
+		`SELECT `+holdColumns+`
+   FROM holds h JOIN merch_items m ON m.id = h.item_id
+   WHERE h.user_id = $1
+   ORDER BY h.created_at DESC, h.id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holds: %w", err)
+	}
+	defer rows.Close()
+
+	holds := []model.Hold{}
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan hold: %w", err)
+		}
+		holds = append(holds, hold)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating hold rows: %w", err)
+	}
+
+	return holds, nil
+}
+
+// Resolve moves the hold to a final status, after which its coins are no longer held.
+func (r *HoldRepository) Resolve(ctx context.Context, id int, status string) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		"UPDATE holds SET status = $1, resolved_at = CURRENT_TIMESTAMP WHERE id = $2",
+		status, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to resolve hold: %w", err)
+	}
+	return nil
+}
+
+// LinkPurchase points a captured hold at the purchase it became.
+func (r *HoldRepository) LinkPurchase(ctx context.Context, id int, purchaseID int) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		"UPDATE holds SET purchase_id = $1 WHERE id = $2", purchaseID, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to link hold to purchase: %w", err)
+	}
+	return nil
+}
+
+// ExpireDue marks the user's active holds that passed their expiry.
+func (r *HoldRepository) ExpireDue(ctx context.Context, userID int) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		`UPDATE holds SET status = 'expired', resolved_at = expires_at
+   WHERE user_id = $1 AND status = 'active' AND expires_at <= CURRENT_TIMESTAMP`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to expire holds: %w", err)
+	}
+	return nil
+}
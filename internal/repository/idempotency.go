@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+)
+
+var ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+
+type IdempotencyRepository struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+func NewIdempotencyRepositoryWithTx(tx *sql.Tx) *IdempotencyRepository {
+	return &IdempotencyRepository{tx: tx}
+}
+
+// Reserve inserts the key for the user. It returns false if the key already exists.
+// A concurrent transaction holding the same key blocks the insert until it finishes.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, userID int, key, requestHash string) (bool, error) {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	res, err := execContext(ctx,
+		`INSERT INTO idempotency_keys (user_id, key, request_hash) VALUES ($1, $2, $3)
+   ON CONFLICT (user_id, key) DO NOTHING`,
+		userID, key, requestHash,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows count after insert: %w", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *IdempotencyRepository) Get(ctx context.Context, userID int, key string) (*model.IdempotencyRecord, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	record := model.IdempotencyRecord{UserID: userID, Key: key}
+	var status sql.NullInt64
+	err := queryRow(ctx,
+		`SELECT request_hash, response_status, response_body, created_at
+   FROM idempotency_keys
+   WHERE user_id = $1 AND key = $2`, userID, key,
+	).Scan(&record.RequestHash, &status, &record.ResponseBody, &record.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdempotencyKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	record.ResponseStatus = int(status.Int64)
+	return &record, nil
+}
+
+func (r *IdempotencyRepository) SaveResponse(ctx context.Context, userID int, key string, status int, body []byte) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	res, err := execContext(ctx,
+		"UPDATE idempotency_keys SET response_status = $1, response_body = $2 WHERE user_id = $3 AND key = $4",
+		status, body, userID, key,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save idempotent response: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows count after update: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrIdempotencyKeyNotFound
+	}
+	return nil
+}
+
+// DeleteCreatedBefore deletes the keys created before t and returns how many were deleted.
+func (r *IdempotencyRepository) DeleteCreatedBefore(ctx context.Context, t time.Time) (int64, error) {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	res, err := execContext(ctx, "DELETE FROM idempotency_keys WHERE created_at < $1", t)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows count after delete: %w", err)
+	}
+	return deleted, nil
+}
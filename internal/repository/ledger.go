@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+)
+
+var (
+	ErrUnbalancedEntry       = errors.New("journal entry is not balanced")
+	ErrLedgerAccountNotFound = errors.New("ledger account not found")
+	ErrNegativeBalance       = errors.New("available balance would become negative")
+)
+
+type LedgerRepository struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+func NewLedgerRepository(db *sql.DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+func NewLedgerRepositoryWithTx(tx *sql.Tx) *LedgerRepository {
+	return &LedgerRepository{tx: tx}
+}
+
+// Post writes the entry with its postings and applies user postings to the cached users.coins.
+// It has to run inside a transaction, since the entry is only checked for balance at commit.
+// A debit may not touch coins reserved by holds; callers lock the user row first so the
+// holds are read after any concurrent hold has committed.
+func (r *LedgerRepository) Post(ctx context.Context, entry model.JournalEntry) (int, error) {
+	if r.tx == nil {
+		return 0, errors.New("ledger posting requires a transaction")
+	}
+
+	if !entry.Balanced() {
+		return 0, ErrUnbalancedEntry
+	}
+
+	var entryID int
+	err := r.tx.QueryRowContext(ctx,
+		"INSERT INTO journal_entries (kind, description) VALUES ($1, $2) RETURNING id",
+		entry.Kind, entry.Description,
+	).Scan(&entryID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create journal entry: %w", err)
+	}
+
+	for _, posting := range entry.Postings {
+		if err := r.insertPosting(ctx, entryID, posting); err != nil {
+			return 0, err
+		}
+		if posting.UserID == 0 {
+			continue
+		}
+
+		// The condition keeps the available balance from going negative even if the caller's check raced.
+		query := "UPDATE users SET coins = coins + $1 WHERE id = $2 AND coins + $1 >= 0"
+		if posting.Amount < 0 {
+			query += " AND coins + $1 >= " + heldCoinsSQL("$2")
+		}
+		res, err := r.tx.ExecContext(ctx, query, posting.Amount, posting.UserID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update user coins: %w", err)
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get affected rows count after update: %w", err)
+		}
+		if rowsAffected == 0 {
+			if _, err := NewUserRepositoryWithTx(r.tx).GetCoins(ctx, posting.UserID); err != nil {
+				return 0, err
+			}
+			return 0, ErrNegativeBalance
+		}
+	}
+
+	return entryID, nil
+}
+
+func (r *LedgerRepository) insertPosting(ctx context.Context, entryID int, posting model.Posting) error {
+	var res sql.Result
+	var err error
+	if posting.UserID != 0 {
+		res, err = r.tx.ExecContext(ctx,
+			`INSERT INTO postings (entry_id, account_id, amount)
+   SELECT $1, id, $2 FROM ledger_accounts WHERE user_id = $3`,
+			entryID, posting.Amount, posting.UserID,
+		)
+	} else {
+		res, err = r.tx.ExecContext(ctx,
+			`INSERT INTO postings (entry_id, account_id, amount)
+   SELECT $1, id, $2 FROM ledger_accounts WHERE code = $3`,
+			entryID, posting.Amount, posting.AccountCode,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create posting: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows count after insert: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrLedgerAccountNotFound
+	}
+	return nil
+}
+
+// Report checks that every entry balances and that cached user balances match the ledger.
+func (r *LedgerRepository) Report(ctx context.Context) (*model.LedgerReport, error) {
+	var queryContext func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryContext = r.tx.QueryContext
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryContext = r.db.QueryContext
+		queryRow = r.db.QueryRowContext
+	}
+
+	report := &model.LedgerReport{
+		UnbalancedEntries: []int{},
+		BalanceMismatches: []model.BalanceMismatch{},
+		SystemAccounts:    []model.AccountBalance{},
+	}
+
+	if err := queryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM postings").Scan(&report.TotalPostings); err != nil {
+		return nil, fmt.Errorf("failed to sum postings: %w", err)
+	}
+
+	rows, err := queryContext(ctx,
+		`SELECT entry_id FROM postings GROUP BY entry_id HAVING SUM(amount) <> 0 ORDER BY entry_id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unbalanced entries: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entryID int
+		if err := rows.Scan(&entryID); err != nil {
+			return nil, fmt.Errorf("failed to scan unbalanced entry: %w", err)
+		}
+		report.UnbalancedEntries = append(report.UnbalancedEntries, entryID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating unbalanced entry rows: %w", err)
+	}
+
+	mismatchRows, err := queryContext(ctx,
+		`SELECT u.id, u.coins, COALESCE(SUM(p.amount), 0)
+   FROM users u
+   LEFT JOIN ledger_accounts a ON a.user_id = u.id
+   LEFT JOIN postings p ON p.account_id = a.id
+   GROUP BY u.id, u.coins
+   HAVING u.coins <> COALESCE(SUM(p.amount), 0)
+   ORDER BY u.id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query balance mismatches: %w", err)
+	}
+	defer mismatchRows.Close()
+	for mismatchRows.Next() {
+		var m model.BalanceMismatch
+		if err := mismatchRows.Scan(&m.UserID, &m.CachedBalance, &m.LedgerBalance); err != nil {
+			return nil, fmt.Errorf("failed to scan balance mismatch: %w", err)
+		}
+		report.BalanceMismatches = append(report.BalanceMismatches, m)
+	}
+	if err := mismatchRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating balance mismatch rows: %w", err)
+	}
+
+	accountRows, err := queryContext(ctx,
+		`SELECT a.code, COALESCE(SUM(p.amount), 0)
+   FROM ledger_accounts a
+   LEFT JOIN postings p ON p.account_id = a.id
+   WHERE a.kind = 'system'
+   GROUP BY a.code
+   ORDER BY a.code`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system accounts: %w", err)
+	}
+	defer accountRows.Close()
+	for accountRows.Next() {
+		var b model.AccountBalance
+		if err := accountRows.Scan(&b.Code, &b.Balance); err != nil {
+			return nil, fmt.Errorf("failed to scan system account: %w", err)
+		}
+		report.SystemAccounts = append(report.SystemAccounts, b)
+	}
+	if err := accountRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating system account rows: %w", err)
+	}
+
+	report.Balanced = report.TotalPostings == 0 &&
+		len(report.UnbalancedEntries) == 0 &&
+		len(report.BalanceMismatches) == 0
+	return report, nil
+}
@@ -2,46 +2,248 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
 )
 
-var ErrMerchItemNotFound = errors.New("merch item not found")
+var (
+	ErrMerchItemNotFound = errors.New("merch item not found")
+	ErrMerchItemExists   = errors.New("merch item already exists")
+	ErrMerchOutOfStock   = errors.New("merch item out of stock")
+)
+
+const merchItemColumns = "id, name, price, description, category, active, stock, created_at, updated_at"
 
 type MerchRepository struct {
-	merchItems map[string]model.Merch
+	db *sql.DB
+	tx *sql.Tx
+}
+
+func NewMerchRepository(db *sql.DB) *MerchRepository {
+	return &MerchRepository{db: db}
 }
 
-func NewMerchRepository() *MerchRepository {
-	// Initialize with predefined merch items
-	items := map[string]model.Merch{
-		"t-shirt":    {Name: "t-shirt", Price: 80},
-		"cup":        {Name: "cup", Price: 20},
-		"book":       {Name: "book", Price: 50},
-		"pen":        {Name: "pen", Price: 10},
-		"powerbank":  {Name: "powerbank", Price: 200},
-		"hoody":      {Name: "hoody", Price: 300},
-		"umbrella":   {Name: "umbrella", Price: 200},
-		"socks":      {Name: "socks", Price: 10},
-		"wallet":     {Name: "wallet", Price: 50},
-		"pink-hoody": {Name: "pink-hoody", Price: 500},
+func NewMerchRepositoryWithTx(tx *sql.Tx) *MerchRepository {
+	return &MerchRepository{tx: tx}
+}
+
+func scanMerchItem(row interface{ Scan(...interface{}) error }) (model.Merch, error) {
+	var item model.Merch
+	var stock sql.NullInt64
+	err := row.Scan(&item.ID, &item.Name, &item.Price, &item.Description, &item.Category, &item.Active, &stock, &item.CreatedAt, &item.UpdatedAt)
+	if stock.Valid {
+		value := int(stock.Int64)
+		item.Stock = &value
 	}
-	return &MerchRepository{merchItems: items}
+	return item, err
 }
 
+// GetMerchItemByName returns an active item only, so deactivated items cannot be bought.
 func (r *MerchRepository) GetMerchItemByName(ctx context.Context, itemName string) (model.Merch, error) {
-	item, exists := r.merchItems[itemName]
-	if !exists {
-		return model.Merch{}, ErrMerchItemNotFound
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	item, err := scanMerchItem(queryRow(ctx,
+		"SELECT "+merchItemColumns+" FROM merch_items WHERE name = $1 AND active", itemName,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Merch{}, ErrMerchItemNotFound
+		}
+		return model.Merch{}, fmt.Errorf("failed to get merch item by name: %w", err)
+	}
+	return item, nil
+}
+
+func (r *MerchRepository) GetMerchItemByID(ctx context.Context, id int) (model.Merch, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	item, err := scanMerchItem(queryRow(ctx,
+		"SELECT "+merchItemColumns+" FROM merch_items WHERE id = $1", id,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Merch{}, ErrMerchItemNotFound
+		}
+		return model.Merch{}, fmt.Errorf("failed to get merch item by ID: %w", err)
 	}
 	return item, nil
 }
 
+// ListMerchItems returns active items with their active variants, ordered by ID.
 func (r *MerchRepository) ListMerchItems(ctx context.Context) ([]model.Merch, error) {
-	items := make([]model.Merch, 0, len(r.merchItems))
-	for _, item := range r.merchItems {
+	return r.listMerchItems(ctx, "SELECT "+merchItemColumns+" FROM merch_items WHERE active ORDER BY id", true)
+}
+
+// ListAllMerchItems returns active and deactivated items with all their variants, ordered by ID.
+func (r *MerchRepository) ListAllMerchItems(ctx context.Context) ([]model.Merch, error) {
+	return r.listMerchItems(ctx, "SELECT "+merchItemColumns+" FROM merch_items ORDER BY id", false)
+}
+
+func (r *MerchRepository) listMerchItems(ctx context.Context, query string, activeVariants bool) ([]model.Merch, error) {
+	var queryContext func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	if r.tx != nil {
+		queryContext = r.tx.QueryContext
+	} else {
+		queryContext = r.db.QueryContext
+	}
+
+	rows, err := queryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query merch items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []model.Merch{}
+	for rows.Next() {
+		item, err := scanMerchItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan merch item: %w", err)
+		}
 		items = append(items, item)
 	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating merch item rows: %w", err)
+	}
+
+	if err := r.attachVariants(ctx, items, activeVariants); err != nil {
+		return nil, err
+	}
 	return items, nil
 }
+
+func (r *MerchRepository) CreateMerchItem(ctx context.Context, item model.Merch) (model.Merch, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	created, err := scanMerchItem(queryRow(ctx,
+		`INSERT INTO merch_items (name, price, description, category, active, stock) VALUES ($1, $2, $3, $4, $5, $6)
+   RETURNING `+merchItemColumns,
+		item.Name, item.Price, item.Description, item.Category, item.Active, item.Stock,
+	))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return model.Merch{}, ErrMerchItemExists
+		}
+		return model.Merch{}, fmt.Errorf("failed to create merch item: %w", err)
+	}
+	return created, nil
+}
+
+func (r *MerchRepository) UpdateMerchItem(ctx context.Context, item model.Merch) (model.Merch, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	updated, err := scanMerchItem(queryRow(ctx,
+		`UPDATE merch_items
+   SET name = $1, price = $2, description = $3, category = $4, active = $5, updated_at = CURRENT_TIMESTAMP
+   WHERE id = $6
+   RETURNING `+merchItemColumns,
+		item.Name, item.Price, item.Description, item.Category, item.Active, item.ID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Merch{}, ErrMerchItemNotFound
+		}
+		if isUniqueViolation(err) {
+			return model.Merch{}, ErrMerchItemExists
+		}
+		return model.Merch{}, fmt.Errorf("failed to update merch item: %w", err)
+	}
+	return updated, nil
+}
+
+// DecrementStock takes quantity units of an active item. Items without stock tracking always succeed.
+func (r *MerchRepository) DecrementStock(ctx context.Context, itemID int, quantity int) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	res, err := execContext(ctx,
+		`UPDATE merch_items
+   SET stock = stock - $1, updated_at = CURRENT_TIMESTAMP
+   WHERE id = $2 AND active AND (stock IS NULL OR stock >= $1)`,
+		quantity, itemID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to decrement merch stock: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows count after update: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrMerchOutOfStock
+	}
+	return nil
+}
+
+// Restock adds quantity units to the item, starting stock tracking if it was not tracked before.
+func (r *MerchRepository) Restock(ctx context.Context, itemID int, quantity int) (model.Merch, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	item, err := scanMerchItem(queryRow(ctx,
+		`UPDATE merch_items
+   SET stock = COALESCE(stock, 0) + $1, updated_at = CURRENT_TIMESTAMP
+   WHERE id = $2
+   RETURNING `+merchItemColumns,
+		quantity, itemID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Merch{}, ErrMerchItemNotFound
+		}
+		return model.Merch{}, fmt.Errorf("failed to restock merch item: %w", err)
+	}
+	return item, nil
+}
+
+// ReturnToStock puts quantity units of a cancelled order back. Items without stock tracking are left alone.
+func (r *MerchRepository) ReturnToStock(ctx context.Context, id int, quantity int) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		`UPDATE merch_items
+   SET stock = stock + $1, updated_at = CURRENT_TIMESTAMP
+   WHERE id = $2 AND stock IS NOT NULL`,
+		quantity, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to return merch stock: %w", err)
+	}
+	return nil
+}
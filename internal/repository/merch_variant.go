@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+)
+
+var (
+	ErrMerchVariantNotFound = errors.New("merch variant not found")
+	ErrMerchVariantExists   = errors.New("merch variant already exists")
+)
+
+// merchVariantColumns are selected from merch_variants v joined with its item m,
+// since a variant without a price override costs as much as its item.
+const merchVariantColumns = `v.id, v.merch_item_id, v.sku, v.size, v.color, COALESCE(v.price, m.price), v.price,
+   v.stock, v.active, v.created_at, v.updated_at`
+
+func scanMerchVariant(row interface{ Scan(...interface{}) error }) (model.MerchVariant, error) {
+	var v model.MerchVariant
+	var priceOverride, stock sql.NullInt64
+	err := row.Scan(&v.ID, &v.MerchID, &v.SKU, &v.Size, &v.Color, &v.Price, &priceOverride,
+		&stock, &v.Active, &v.CreatedAt, &v.UpdatedAt)
+	if priceOverride.Valid {
+		value := int(priceOverride.Int64)
+		v.PriceOverride = &value
+	}
+	if stock.Valid {
+		value := int(stock.Int64)
+		v.Stock = &value
+	}
+	return v, err
+}
+
+// attachVariants sets the variants of every item, active ones only if activeOnly is set.
+func (r *MerchRepository) attachVariants(ctx context.Context, items []model.Merch, activeOnly bool) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	var queryContext func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	if r.tx != nil {
+		queryContext = r.tx.QueryContext
+	} else {
+		queryContext = r.db.QueryContext
+	}
+
+	itemIDs := make([]int64, len(items))
+	for i, item := range items {
+		itemIDs[i] = int64(item.ID)
+	}
+
+	query := `SELECT ` + merchVariantColumns + `
+   FROM merch_variants v JOIN merch_items m ON m.id = v.merch_item_id
+   WHERE v.merch_item_id = ANY($1)`
+	if activeOnly {
+		query += " AND v.active"
+	}
+	query += " ORDER BY v.merch_item_id, v.id"
+
+	rows, err := queryContext(ctx, query, pq.Array(itemIDs))
+	if err != nil {
+		return fmt.Errorf("failed to query merch variants: %w", err)
+	}
+	defer rows.Close()
+
+	variants := make(map[int][]model.MerchVariant)
+	for rows.Next() {
+		v, err := scanMerchVariant(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan merch variant: %w", err)
+		}
+		variants[v.MerchID] = append(variants[v.MerchID], v)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating merch variant rows: %w", err)
+	}
+
+	for i := range items {
+		items[i].Variants = variants[items[i].ID]
+	}
+	return nil
+}
+
+// GetVariantBySKU returns an active variant of an active item only, so neither can be bought once deactivated.
+func (r *MerchRepository) GetVariantBySKU(ctx context.Context, sku string) (model.MerchVariant, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	v, err := scanMerchVariant(queryRow(ctx,
+		`SELECT `+merchVariantColumns+`
+   FROM merch_variants v JOIN merch_items m ON m.id = v.merch_item_id
+   WHERE v.sku = $1 AND v.active AND m.active`, sku,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.MerchVariant{}, ErrMerchVariantNotFound
+		}
+		return model.MerchVariant{}, fmt.Errorf("failed to get merch variant by SKU: %w", err)
+	}
+	return v, nil
+}
+
+func (r *MerchRepository) GetVariantByID(ctx context.Context, itemID, variantID int) (model.MerchVariant, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	v, err := scanMerchVariant(queryRow(ctx,
+		`SELECT `+merchVariantColumns+`
+   FROM merch_variants v JOIN merch_items m ON m.id = v.merch_item_id
+   WHERE v.id = $1 AND v.merch_item_id = $2`, variantID, itemID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.MerchVariant{}, ErrMerchVariantNotFound
+		}
+		return model.MerchVariant{}, fmt.Errorf("failed to get merch variant by ID: %w", err)
+	}
+	return v, nil
+}
+
+// HasActiveVariants reports whether the item can only be bought as one of its variants.
+func (r *MerchRepository) HasActiveVariants(ctx context.Context, itemID int) (bool, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	var exists bool
+	err := queryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM merch_variants WHERE merch_item_id = $1 AND active)", itemID,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check merch variants: %w", err)
+	}
+	return exists, nil
+}
+
+func (r *MerchRepository) CreateVariant(ctx context.Context, v model.MerchVariant) (model.MerchVariant, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	created, err := scanMerchVariant(queryRow(ctx,
+		`WITH v AS (
+     INSERT INTO merch_variants (merch_item_id, sku, size, color, price, stock, active)
+     VALUES ($1, $2, $3, $4, $5, $6, $7)
+     RETURNING *
+   )
+   SELECT `+merchVariantColumns+` FROM v JOIN merch_items m ON m.id = v.merch_item_id`,
+		v.MerchID, v.SKU, v.Size, v.Color, v.PriceOverride, v.Stock, v.Active,
+	))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return model.MerchVariant{}, ErrMerchVariantExists
+		}
+		return model.MerchVariant{}, fmt.Errorf("failed to create merch variant: %w", err)
+	}
+	return created, nil
+}
+
+// UpdateVariant saves the price override and the active flag of the variant.
+func (r *MerchRepository) UpdateVariant(ctx context.Context, v model.MerchVariant) (model.MerchVariant, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	updated, err := scanMerchVariant(queryRow(ctx,
+		`WITH v AS (
+     UPDATE merch_variants SET price = $1, active = $2, updated_at = CURRENT_TIMESTAMP
+     WHERE id = $3 AND merch_item_id = $4
+     RETURNING *
+   )
+   SELECT `+merchVariantColumns+` FROM v JOIN merch_items m ON m.id = v.merch_item_id`,
+		v.PriceOverride, v.Active, v.ID, v.MerchID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.MerchVariant{}, ErrMerchVariantNotFound
+		}
+		return model.MerchVariant{}, fmt.Errorf("failed to update merch variant: %w", err)
+	}
+	return updated, nil
+}
+
+// RestockVariant adds quantity units to the variant, starting stock tracking if it was not tracked before.
+func (r *MerchRepository) RestockVariant(ctx context.Context, itemID, variantID int, quantity int) (model.MerchVariant, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	v, err := scanMerchVariant(queryRow(ctx,
+		`WITH v AS (
+     UPDATE merch_variants SET stock = COALESCE(stock, 0) + $1, updated_at = CURRENT_TIMESTAMP
+     WHERE id = $2 AND merch_item_id = $3
+     RETURNING *
+   )
+   SELECT `+merchVariantColumns+` FROM v JOIN merch_items m ON m.id = v.merch_item_id`,
+		quantity, variantID, itemID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.MerchVariant{}, ErrMerchVariantNotFound
+		}
+		return model.MerchVariant{}, fmt.Errorf("failed to restock merch variant: %w", err)
+	}
+	return v, nil
+}
+
+// DecrementVariantStock takes quantity units of an active variant. Variants without stock tracking always succeed.
+func (r *MerchRepository) DecrementVariantStock(ctx context.Context, variantID int, quantity int) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	res, err := execContext(ctx,
+		`UPDATE merch_variants
+   SET stock = stock - $1, updated_at = CURRENT_TIMESTAMP
+   WHERE id = $2 AND active AND (stock IS NULL OR stock >= $1)`,
+		quantity, variantID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to decrement merch variant stock: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows count after update: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrMerchOutOfStock
+	}
+	return nil
+}
+
+// ReturnVariantToStock puts quantity units of a cancelled order back. Variants without stock tracking are left alone.
+func (r *MerchRepository) ReturnVariantToStock(ctx context.Context, sku string, quantity int) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		`UPDATE merch_variants
+   SET stock = stock + $1, updated_at = CURRENT_TIMESTAMP
+   WHERE sku = $2 AND stock IS NOT NULL`,
+		quantity, sku,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to return merch variant stock: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+)
+
+var ErrMoneyRequestNotFound = errors.New("money request not found")
+
+const moneyRequestColumns = "id, requester_id, payer_id, amount, memo, status, transaction_id, created_at, expires_at, resolved_at"
+
+type MoneyRequestRepository struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+func NewMoneyRequestRepository(db *sql.DB) *MoneyRequestRepository {
+	return &MoneyRequestRepository{db: db}
+}
+
+func NewMoneyRequestRepositoryWithTx(tx *sql.Tx) *MoneyRequestRepository {
+	return &MoneyRequestRepository{tx: tx}
+}
+
+func scanMoneyRequest(row interface{ Scan(...interface{}) error }) (model.MoneyRequest, error) {
+	var mr model.MoneyRequest
+	var transactionID sql.NullInt64
+	var resolvedAt sql.NullTime
+	err := row.Scan(&mr.ID, &mr.RequesterID, &mr.PayerID, &mr.Amount, &mr.Memo, &mr.Status, &transactionID,
+		&mr.CreatedAt, &mr.ExpiresAt, &resolvedAt)
+	mr.TransactionID = int(transactionID.Int64)
+	if resolvedAt.Valid {
+		mr.ResolvedAt = &resolvedAt.Time
+	}
+	return mr, err
+}
+
+func (r *MoneyRequestRepository) Create(ctx context.Context, mr model.MoneyRequest) (model.MoneyRequest, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	created, err := scanMoneyRequest(queryRow(ctx,
+		`INSERT INTO money_requests (requester_id, payer_id, amount, memo, expires_at) VALUES ($1, $2, $3, $4, $5)
+   RETURNING `+moneyRequestColumns,
+		mr.RequesterID, mr.PayerID, mr.Amount, mr.Memo, mr.ExpiresAt,
+	))
+	if err != nil {
+		return model.MoneyRequest{}, fmt.Errorf("failed to create money request: %w", err)
+	}
+	return created, nil
+}
+
+// GetForUpdate locks the request row until the transaction ends.
+func (r *MoneyRequestRepository) GetForUpdate(ctx context.Context, id int) (model.MoneyRequest, error) {
+	if r.tx == nil {
+		return model.MoneyRequest{}, errors.New("row lock requires a transaction")
+	}
+
+	mr, err := scanMoneyRequest(r.tx.QueryRowContext(ctx,
+		"SELECT "+moneyRequestColumns+" FROM money_requests WHERE id = $1 FOR UPDATE", id,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.MoneyRequest{}, ErrMoneyRequestNotFound
+		}
+		return model.MoneyRequest{}, fmt.Errorf("failed to lock money request: %w", err)
+	}
+	return mr, nil
+}
+
+// ListByPayer returns requests addressed to the payer, newest first. An empty status returns all of them.
+func (r *MoneyRequestRepository) ListByPayer(ctx context.Context, payerID int, status string) ([]model.MoneyRequest, error) {
+	return r.list(ctx, "payer_id", payerID, status)
+}
+
+// ListByRequester returns requests created by the requester, newest first. An empty status returns all of them.
+func (r *MoneyRequestRepository) ListByRequester(ctx context.Context, requesterID int, status string) ([]model.MoneyRequest, error) {
+	return r.list(ctx, "requester_id", requesterID, status)
+}
+
+func (r *MoneyRequestRepository) list(ctx context.Context, userColumn string, userID int, status string) ([]model.MoneyRequest, error) {
+	var queryContext func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	if r.tx != nil {
+		queryContext = r.tx.QueryContext
+	} else {
+		queryContext = r.db.QueryContext
+	}
+
+	rows, err := queryContext(ctx,
+		`SELECT `+moneyRequestColumns+`
+   FROM money_requests
+   WHERE `+userColumn+` = $1 AND ($2::text = '' OR status = $2)
+   ORDER BY created_at DESC, id DESC`,
+		userID, status,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query money requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := []model.MoneyRequest{}
+	for rows.Next() {
+		mr, err := scanMoneyRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan money request: %w", err)
+		}
+		requests = append(requests, mr)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating money request rows: %w", err)
+	}
+
+	return requests, nil
+}
+
+// Resolve moves the request to a final status. transactionID is set for accepted requests only.
+func (r *MoneyRequestRepository) Resolve(ctx context.Context, id int, status string, transactionID int) (model.MoneyRequest, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	mr, err := scanMoneyRequest(queryRow(ctx,
+		`UPDATE money_requests SET status = $1, transaction_id = $2, resolved_at = CURRENT_TIMESTAMP
+   WHERE id = $3
+   RETURNING `+moneyRequestColumns,
+		status, nullableID(transactionID), id,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.MoneyRequest{}, ErrMoneyRequestNotFound
+		}
+		return model.MoneyRequest{}, fmt.Errorf("failed to resolve money request: %w", err)
+	}
+	return mr, nil
+}
+
+// ExpirePending marks the user's pending requests that expired by now, on either side.
+func (r *MoneyRequestRepository) ExpirePending(ctx context.Context, userID int, now time.Time) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		`UPDATE money_requests SET status = 'expired', resolved_at = expires_at
+   WHERE status = 'pending' AND expires_at <= $1 AND (payer_id = $2 OR requester_id = $2)`,
+		now, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to expire money requests: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"serialization failure", &pq.Error{Code: pgSerializationFailure}, true},
+		{"deadlock", &pq.Error{Code: pgDeadlockDetected}, true},
+		{"wrapped deadlock", fmt.Errorf("failed to lock users: %w", &pq.Error{Code: pgDeadlockDetected}), true},
+		{"unique violation", &pq.Error{Code: pgUniqueViolation}, false},
+		{"check violation", &pq.Error{Code: "23514"}, false},
+		{"not a postgres error", errors.New("connection refused"), false},
+		{"no rows", sql.ErrNoRows, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsUniqueViolationOf(t *testing.T) {
+	usernameTaken := &pq.Error{Code: pgUniqueViolation, Constraint: usernameUniqueConstraint}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"username", usernameTaken, true},
+		{"wrapped username", fmt.Errorf("insert: %w", usernameTaken), true},
+		{"other constraint", &pq.Error{Code: pgUniqueViolation, Constraint: "users_pkey"}, false},
+		{"other code", &pq.Error{Code: "23514", Constraint: usernameUniqueConstraint}, false},
+		{"not a postgres error", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUniqueViolationOf(tt.err, usernameUniqueConstraint); got != tt.want {
+				t.Errorf("isUniqueViolationOf(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+)
+
+var (
+	ErrPromotionNotFound   = errors.New("promotion not found")
+	ErrPromotionCodeExists = errors.New("promotion code already exists")
+)
+
+const promotionColumns = `id, COALESCE(code, ''), name, discount_type, discount_value, COALESCE(item_name, ''),
+   COALESCE(category, ''), starts_at, ends_at, max_uses, max_uses_per_user, uses, stackable, active, created_at`
+
+type PromotionRepository struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+func NewPromotionRepository(db *sql.DB) *PromotionRepository {
+	return &PromotionRepository{db: db}
+}
+
+func NewPromotionRepositoryWithTx(tx *sql.Tx) *PromotionRepository {
+	return &PromotionRepository{tx: tx}
+}
+
+func scanPromotion(row interface{ Scan(...interface{}) error }) (model.Promotion, error) {
+	var p model.Promotion
+	var endsAt sql.NullTime
+	var maxUses, maxUsesPerUser sql.NullInt64
+	err := row.Scan(&p.ID, &p.Code, &p.Name, &p.DiscountType, &p.DiscountValue, &p.ItemName,
+		&p.Category, &p.StartsAt, &endsAt, &maxUses, &maxUsesPerUser, &p.Uses, &p.Stackable, &p.Active, &p.CreatedAt)
+	if endsAt.Valid {
+		p.EndsAt = &endsAt.Time
+	}
+	if maxUses.Valid {
+		value := int(maxUses.Int64)
+		p.MaxUses = &value
+	}
+	if maxUsesPerUser.Valid {
+		value := int(maxUsesPerUser.Int64)
+		p.MaxUsesPerUser = &value
+	}
+	return p, err
+}
+
+func (r *PromotionRepository) Create(ctx context.Context, p model.Promotion) (model.Promotion, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	created, err := scanPromotion(queryRow(ctx,
+		`INSERT INTO promotions (code, name, discount_type, discount_value, item_name, category,
+     starts_at, ends_at, max_uses, max_uses_per_user, stackable)
+   VALUES (NULLIF($1, ''), $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9, $10, $11)
+   RETURNING `+promotionColumns,
+		p.Code, p.Name, p.DiscountType, p.DiscountValue, p.ItemName, p.Category,
+		p.StartsAt, p.EndsAt, p.MaxUses, p.MaxUsesPerUser, p.Stackable,
+	))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return model.Promotion{}, ErrPromotionCodeExists
+		}
+		return model.Promotion{}, fmt.Errorf("failed to create promotion: %w", err)
+	}
+	return created, nil
+}
+
+// List returns all promotions, newest first.
+func (r *PromotionRepository) List(ctx context.Context) ([]model.Promotion, error) {
+	var queryContext func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	if r.tx != nil {
+		queryContext = r.tx.QueryContext
+	} else {
+		queryContext = r.db.QueryContext
+	}
+
+	rows, err := queryContext(ctx, "SELECT "+promotionColumns+" FROM promotions ORDER BY id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query promotions: %w", err)
+	}
+	defer rows.Close()
+
+	promotions := []model.Promotion{}
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan promotion: %w", err)
+		}
+		promotions = append(promotions, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating promotion rows: %w", err)
+	}
+
+	return promotions, nil
+}
+
+func (r *PromotionRepository) Deactivate(ctx context.Context, id int) (model.Promotion, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	p, err := scanPromotion(queryRow(ctx,
+		"UPDATE promotions SET active = FALSE WHERE id = $1 RETURNING "+promotionColumns, id,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Promotion{}, ErrPromotionNotFound
+		}
+		return model.Promotion{}, fmt.Errorf("failed to deactivate promotion: %w", err)
+	}
+	return p, nil
+}
+
+// LockForPurchase locks and returns the promotion with the given code, whatever its state, together with
+// the active automatic promotions running now for item. The rows stay locked until the transaction ends,
+// so usage caps are checked and consumed by one purchase at a time. Rows are locked in ID order.
+func (r *PromotionRepository) LockForPurchase(ctx context.Context, code string, item model.Merch) ([]model.Promotion, error) {
+	if r.tx == nil {
+		return nil, errors.New("row lock requires a transaction")
+	}
+
+	rows, err := r.tx.QueryContext(ctx,
+		`SELECT `+promotionColumns+` FROM promotions
+   WHERE ($1 <> '' AND code = $1)
+      OR (code IS NULL AND active
+          AND starts_at <= CURRENT_TIMESTAMP AND (ends_at IS NULL OR ends_at > CURRENT_TIMESTAMP)
+          AND (item_name IS NULL OR item_name = $2)
+          AND (category IS NULL OR category = $3))
+   ORDER BY id
+   FOR UPDATE`,
+		code, item.Name, item.Category,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock promotions: %w", err)
+	}
+	defer rows.Close()
+
+	promotions := []model.Promotion{}
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan promotion: %w", err)
+		}
+		promotions = append(promotions, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating promotion rows: %w", err)
+	}
+
+	return promotions, nil
+}
+
+// CountUserRedemptions returns how many times the user has used the promotion.
+func (r *PromotionRepository) CountUserRedemptions(ctx context.Context, promotionID, userID int) (int, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	var count int
+	err := queryRow(ctx,
+		"SELECT COUNT(*) FROM promotion_redemptions WHERE promotion_id = $1 AND user_id = $2",
+		promotionID, userID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count promotion redemptions: %w", err)
+	}
+	return count, nil
+}
+
+// Redeem records the use of a promotion on a purchase and counts it against the global cap.
+func (r *PromotionRepository) Redeem(ctx context.Context, userID, purchaseID int, redemption model.Redemption) error {
+	if r.tx == nil {
+		return errors.New("promotion redemption requires a transaction")
+	}
+
+	_, err := r.tx.ExecContext(ctx,
+		"INSERT INTO promotion_redemptions (promotion_id, user_id, purchase_id, discount) VALUES ($1, $2, $3, $4)",
+		redemption.PromotionID, userID, purchaseID, redemption.Discount,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record promotion redemption: %w", err)
+	}
+
+	_, err = r.tx.ExecContext(ctx,
+		"UPDATE promotions SET uses = uses + 1 WHERE id = $1", redemption.PromotionID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to count promotion use: %w", err)
+	}
+	return nil
+}
+
+// ReleaseForPurchase deletes the redemptions of a refunded or cancelled purchase and takes them
+// off the global cap, so the buyer and everyone else can use the promotions again.
+func (r *PromotionRepository) ReleaseForPurchase(ctx context.Context, purchaseID int) error {
+	if r.tx == nil {
+		return errors.New("promotion release requires a transaction")
+	}
+
+	_, err := r.tx.ExecContext(ctx,
+		`WITH released AS (
+       DELETE FROM promotion_redemptions WHERE purchase_id = $1 RETURNING promotion_id
+   )
+   UPDATE promotions p SET uses = p.uses - r.uses
+   FROM (SELECT promotion_id, COUNT(*) AS uses FROM released GROUP BY promotion_id) r
+   WHERE p.id = r.promotion_id`,
+		purchaseID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release promotion redemptions: %w", err)
+	}
+	return nil
+}
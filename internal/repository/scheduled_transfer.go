@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+)
+
+var (
+	ErrScheduledTransferNotFound = errors.New("scheduled transfer not found")
+	ErrScheduleStatusConflict    = errors.New("scheduled transfer is not in the expected status")
+)
+
+const scheduledTransferColumns = `id, sender_id, receiver_id, amount, memo, category, interval_seconds, status,
+   next_run_at, last_run_at, created_at, updated_at`
+
+type ScheduledTransferRepository struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+func NewScheduledTransferRepository(db *sql.DB) *ScheduledTransferRepository {
+	return &ScheduledTransferRepository{db: db}
+}
+
+func NewScheduledTransferRepositoryWithTx(tx *sql.Tx) *ScheduledTransferRepository {
+	return &ScheduledTransferRepository{tx: tx}
+}
+
+func scanScheduledTransfer(row interface{ Scan(...interface{}) error }) (model.ScheduledTransfer, error) {
+	var st model.ScheduledTransfer
+	var interval sql.NullInt64
+	var lastRunAt sql.NullTime
+	err := row.Scan(&st.ID, &st.SenderID, &st.ReceiverID, &st.Amount, &st.Memo, &st.Category, &interval, &st.Status,
+		&st.NextRunAt, &lastRunAt, &st.CreatedAt, &st.UpdatedAt)
+	st.IntervalSeconds = int(interval.Int64)
+	if lastRunAt.Valid {
+		st.LastRunAt = &lastRunAt.Time
+	}
+	return st, err
+}
+
+func (r *ScheduledTransferRepository) Create(ctx context.Context, st model.ScheduledTransfer) (model.ScheduledTransfer, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	created, err := scanScheduledTransfer(queryRow(ctx,
+		`INSERT INTO scheduled_transfers (sender_id, receiver_id, amount, memo, category, interval_seconds, next_run_at)
+   VALUES ($1, $2, $3, $4, $5, $6, $7)
+   RETURNING `+scheduledTransferColumns,
+		st.SenderID, st.ReceiverID, st.Amount, st.Memo, st.Category,
+		sql.NullInt64{Int64: int64(st.IntervalSeconds), Valid: st.IntervalSeconds > 0}, st.NextRunAt,
+	))
+	if err != nil {
+		return model.ScheduledTransfer{}, fmt.Errorf("failed to create scheduled transfer: %w", err)
+	}
+	return created, nil
+}
+
+func (r *ScheduledTransferRepository) GetBySender(ctx context.Context, id, senderID int) (model.ScheduledTransfer, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	st, err := scanScheduledTransfer(queryRow(ctx,
+		"SELECT "+scheduledTransferColumns+" FROM scheduled_transfers WHERE id = $1 AND sender_id = $2", id, senderID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ScheduledTransfer{}, ErrScheduledTransferNotFound
+		}
+		return model.ScheduledTransfer{}, fmt.Errorf("failed to get scheduled transfer: %w", err)
+	}
+	return st, nil
+}
+
+func (r *ScheduledTransferRepository) ListBySender(ctx context.Context, senderID int) ([]model.ScheduledTransfer, error) {
+	var queryContext func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	if r.tx != nil {
+		queryContext = r.tx.QueryContext
+	} else {
+		queryContext = r.db.QueryContext
+	}
+
+	rows, err := queryContext(ctx,
+		"SELECT "+scheduledTransferColumns+" FROM scheduled_transfers WHERE sender_id = $1 ORDER BY id", senderID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scheduled transfers: %w", err)
+	}
+	defer rows.Close()
+
+	transfers := []model.ScheduledTransfer{}
+	for rows.Next() {
+		st, err := scanScheduledTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled transfer: %w", err)
+		}
+		transfers = append(transfers, st)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating scheduled transfer rows: %w", err)
+	}
+
+	return transfers, nil
+}
+
+// SetStatus moves the sender's scheduled transfer to status if it is currently in one of from.
+func (r *ScheduledTransferRepository) SetStatus(ctx context.Context, id, senderID int, from []string, status string) (model.ScheduledTransfer, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	st, err := scanScheduledTransfer(queryRow(ctx,
+		`UPDATE scheduled_transfers SET status = $1, updated_at = CURRENT_TIMESTAMP
+   WHERE id = $2 AND sender_id = $3 AND status = ANY($4)
+   RETURNING `+scheduledTransferColumns,
+		status, id, senderID, pq.Array(from),
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			if _, err := r.GetBySender(ctx, id, senderID); err != nil {
+				return model.ScheduledTransfer{}, err
+			}
+			return model.ScheduledTransfer{}, ErrScheduleStatusConflict
+		}
+		return model.ScheduledTransfer{}, fmt.Errorf("failed to update scheduled transfer status: %w", err)
+	}
+	return st, nil
+}
+
+// ClaimDue locks up to limit active transfers due at now. Rows locked by another
+// scheduler are skipped, so replicas polling at the same time split the work.
+func (r *ScheduledTransferRepository) ClaimDue(ctx context.Context, now time.Time, limit int) ([]model.ScheduledTransfer, error) {
+	if r.tx == nil {
+		return nil, errors.New("claiming scheduled transfers requires a transaction")
+	}
+
+	rows, err := r.tx.QueryContext(ctx,
+		`SELECT `+scheduledTransferColumns+`
+   FROM scheduled_transfers
+   WHERE status = 'active' AND next_run_at <= $1
+   ORDER BY next_run_at
+   LIMIT $2
+   FOR UPDATE SKIP LOCKED`,
+		now, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim scheduled transfers: %w", err)
+	}
+	defer rows.Close()
+
+	transfers := []model.ScheduledTransfer{}
+	for rows.Next() {
+		st, err := scanScheduledTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled transfer: %w", err)
+		}
+		transfers = append(transfers, st)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating scheduled transfer rows: %w", err)
+	}
+
+	return transfers, nil
+}
+
+// Advance records that the occurrence at lastRunAt was taken and moves the transfer to its next run.
+func (r *ScheduledTransferRepository) Advance(ctx context.Context, id int, lastRunAt, nextRunAt time.Time, status string) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		`UPDATE scheduled_transfers
+   SET last_run_at = $1, next_run_at = $2, status = $3, updated_at = CURRENT_TIMESTAMP
+   WHERE id = $4`,
+		lastRunAt, nextRunAt, status, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to advance scheduled transfer: %w", err)
+	}
+	return nil
+}
+
+// StartRun records the occurrence as running. It returns false if the occurrence already has a run.
+func (r *ScheduledTransferRepository) StartRun(ctx context.Context, scheduledTransferID int, occurrenceAt time.Time) (int, bool, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	var runID int
+	err := queryRow(ctx,
+		`INSERT INTO scheduled_transfer_runs (scheduled_transfer_id, occurrence_at) VALUES ($1, $2)
+   ON CONFLICT (scheduled_transfer_id, occurrence_at) DO NOTHING
+   RETURNING id`,
+		scheduledTransferID, occurrenceAt,
+	).Scan(&runID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to start scheduled transfer run: %w", err)
+	}
+	return runID, true, nil
+}
+
+func (r *ScheduledTransferRepository) FinishRun(ctx context.Context, runID int, status string, runErr string) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		"UPDATE scheduled_transfer_runs SET status = $1, error = $2, finished_at = CURRENT_TIMESTAMP WHERE id = $3",
+		status, runErr, runID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to finish scheduled transfer run: %w", err)
+	}
+	return nil
+}
+
+// ListRuns returns the runs of a scheduled transfer, newest first.
+func (r *ScheduledTransferRepository) ListRuns(ctx context.Context, scheduledTransferID int) ([]model.ScheduledTransferRun, error) {
+	var queryContext func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	if r.tx != nil {
+		queryContext = r.tx.QueryContext
+	} else {
+		queryContext = r.db.QueryContext
+	}
+
+	rows, err := queryContext(ctx,
+		`SELECT id, scheduled_transfer_id, occurrence_at, status, error, started_at, finished_at
+   FROM scheduled_transfer_runs
+   WHERE scheduled_transfer_id = $1
+   ORDER BY occurrence_at DESC`,
+		scheduledTransferID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scheduled transfer runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []model.ScheduledTransferRun{}
+	for rows.Next() {
+		var run model.ScheduledTransferRun
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&run.ID, &run.ScheduledTransferID, &run.OccurrenceAt, &run.Status, &run.Error,
+			&run.StartedAt, &finishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled transfer run: %w", err)
+		}
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating scheduled transfer run rows: %w", err)
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+)
+
+var (
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+)
+
+type SessionRepository struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func NewSessionRepositoryWithTx(tx *sql.Tx) *SessionRepository {
+	return &SessionRepository{tx: tx}
+}
+
+func (r *SessionRepository) CreateSession(ctx context.Context, sessionID string, userID int) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		"INSERT INTO sessions (id, user_id) VALUES ($1, $2)", sessionID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) IsSessionActive(ctx context.Context, sessionID string, userID int) (bool, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	var active bool
+	err := queryRow(ctx,
+		"SELECT revoked_at IS NULL FROM sessions WHERE id = $1 AND user_id = $2", sessionID, userID,
+	).Scan(&active)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return active, nil
+}
+
+func (r *SessionRepository) RevokeSession(ctx context.Context, sessionID string) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	res, err := execContext(ctx,
+		"UPDATE sessions SET revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP) WHERE id = $1", sessionID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows count after update: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every active session of the user and returns how many were revoked.
+func (r *SessionRepository) RevokeUserSessions(ctx context.Context, userID int) (int, error) {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	res, err := execContext(ctx,
+		"UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL", userID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke user sessions: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows count after update: %w", err)
+	}
+	return int(rowsAffected), nil
+}
+
+func (r *SessionRepository) CreateRefreshToken(ctx context.Context, sessionID, tokenHash string, expiresAt time.Time) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		"INSERT INTO refresh_tokens (session_id, token_hash, expires_at) VALUES ($1, $2, $3)",
+		sessionID, tokenHash, expiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+// GetRefreshTokenForUpdate locks the token row so concurrent refreshes with the same token are serialized.
+func (r *SessionRepository) GetRefreshTokenForUpdate(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	var token model.RefreshToken
+	var usedAt sql.NullTime
+	err := queryRow(ctx,
+		`SELECT t.id, t.session_id, s.user_id, t.expires_at, t.used_at, s.revoked_at IS NOT NULL
+   FROM refresh_tokens t
+   JOIN sessions s ON s.id = t.session_id
+   WHERE t.token_hash = $1
+   FOR UPDATE OF t`, tokenHash,
+	).Scan(&token.ID, &token.SessionID, &token.UserID, &token.ExpiresAt, &usedAt, &token.Revoked)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	return &token, nil
+}
+
+func (r *SessionRepository) MarkRefreshTokenUsed(ctx context.Context, id int) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		"UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = $1", id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark refresh token as used: %w", err)
+	}
+	return nil
+}
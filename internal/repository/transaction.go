@@ -3,12 +3,23 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
+
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
 )
 
+var (
+	ErrTransactionNotFound        = errors.New("transaction not found")
+	ErrTransactionAlreadyReversed = errors.New("transaction already reversed")
+	ErrPurchaseNotFound           = errors.New("purchase not found")
+	ErrPurchaseAlreadyRefunded    = errors.New("purchase already refunded")
+)
+
 type TransactionRepository struct {
 	db *sql.DB
 	tx *sql.Tx
@@ -22,22 +33,61 @@ func NewTransactionRepositoryWithTx(tx *sql.Tx) *TransactionRepository {
 	return &TransactionRepository{tx: tx}
 }
 
-func (r *TransactionRepository) Create(ctx context.Context, senderID, receiverID int, amount int) error {
-	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+// Create records a transfer booked by the journal entry entryID and returns its ID.
+func (r *TransactionRepository) Create(ctx context.Context, t model.Transaction, entryID int) (int, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
 	if r.tx != nil {
-		execContext = r.tx.ExecContext
+		queryRow = r.tx.QueryRowContext
 	} else {
-		execContext = r.db.ExecContext
+		queryRow = r.db.QueryRowContext
 	}
 
-	_, err := execContext(ctx,
-		"INSERT INTO transactions (sender_id, receiver_id, amount) VALUES ($1, $2, $3)",
-		senderID, receiverID, amount,
-	)
-	return err
+	var id int
+	err := queryRow(ctx,
+		`INSERT INTO transactions (sender_id, receiver_id, amount, memo, category, reverses_transaction_id, journal_entry_id)
+   VALUES ($1, $2, $3, $4, $5, $6, $7)
+   RETURNING id`,
+		t.SenderID, t.ReceiverID, t.Amount, t.Memo, t.Category, nullableID(t.ReversesID), nullableID(entryID),
+	).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, ErrTransactionAlreadyReversed
+		}
+		return 0, err
+	}
+	return id, nil
 }
 
-func (r *TransactionRepository) GetTransactionsByUserID(ctx context.Context, userID int) ([]model.Transaction, error) {
+// GetByIDForUpdate locks the transaction row, so it cannot be reversed twice concurrently.
+func (r *TransactionRepository) GetByIDForUpdate(ctx context.Context, id int) (model.Transaction, error) {
+	if r.tx == nil {
+		return model.Transaction{}, errors.New("row lock requires a transaction")
+	}
+
+	var t model.Transaction
+	var reverses, reversedBy sql.NullInt64
+	err := r.tx.QueryRowContext(ctx,
+		`SELECT id, sender_id, receiver_id, amount, memo, category, reverses_transaction_id,
+   (SELECT r.id FROM transactions r WHERE r.reverses_transaction_id = t.id), created_at
+   FROM transactions t
+   WHERE id = $1
+   FOR UPDATE`,
+		id,
+	).Scan(&t.ID, &t.SenderID, &t.ReceiverID, &t.Amount, &t.Memo, &t.Category, &reverses, &reversedBy, &t.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Transaction{}, ErrTransactionNotFound
+		}
+		return model.Transaction{}, fmt.Errorf("failed to lock transaction: %w", err)
+	}
+	t.ReversesID = int(reverses.Int64)
+	t.ReversedByID = int(reversedBy.Int64)
+	return t, nil
+}
+
+// GetTransactionsByUserID returns one page of the user's transfers, newest first, and the cursor
+// of the next page or nil if this is the last one. Each direction is read from its own index.
+func (r *TransactionRepository) GetTransactionsByUserID(ctx context.Context, userID int, filter model.HistoryFilter) ([]model.Transaction, *model.Cursor, error) {
 	var queryContext func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 	if r.tx != nil {
 		queryContext = r.tx.QueryContext
@@ -45,37 +95,172 @@ func (r *TransactionRepository) GetTransactionsByUserID(ctx context.Context, use
 		queryContext = r.db.QueryContext
 	}
 
+	args := queryArgs{}
+	user := args.add(userID)
+
+	var conditions []string
+	if filter.Category != "" {
+		conditions = append(conditions, "category = "+args.add(filter.Category))
+	}
+	if filter.MinAmount > 0 {
+		conditions = append(conditions, "amount >= "+args.add(filter.MinAmount))
+	}
+	if filter.MaxAmount > 0 {
+		conditions = append(conditions, "amount <= "+args.add(filter.MaxAmount))
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "created_at >= "+args.add(*filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "created_at < "+args.add(*filter.To))
+	}
+	if filter.After != nil {
+		conditions = append(conditions,
+			"(created_at, id) < ("+args.add(filter.After.CreatedAt)+", "+args.add(filter.After.ID)+")")
+	}
+	counterparty := ""
+	if filter.CounterpartyID > 0 {
+		counterparty = args.add(filter.CounterpartyID)
+	}
+	limit := args.add(filter.Limit + 1)
+
+	side := func(ownColumn, otherColumn string) string {
+		sideConditions := append([]string{ownColumn + " = " + user}, conditions...)
+		if counterparty != "" {
+			sideConditions = append(sideConditions, otherColumn+" = "+counterparty)
+		}
+		return `(SELECT id, sender_id, receiver_id, amount, memo, category, reverses_transaction_id,
+   (SELECT r.id FROM transactions r WHERE r.reverses_transaction_id = t.id) AS reversed_by_id, created_at
+   FROM transactions t
+   WHERE ` + strings.Join(sideConditions, " AND ") + `
+   ORDER BY created_at DESC, id DESC
+   LIMIT ` + limit + ")"
+	}
+
+	var sides []string
+	if filter.Direction != model.DirectionIncoming {
+		sides = append(sides, side("sender_id", "receiver_id"))
+	}
+	if filter.Direction != model.DirectionOutgoing {
+		sides = append(sides, side("receiver_id", "sender_id"))
+	}
+
 	rows, err := queryContext(ctx,
-		`SELECT id, sender_id, receiver_id, amount, created_at
-   FROM transactions
-   WHERE sender_id = $1 OR receiver_id = $1
-   ORDER BY created_at DESC`, userID,
+		`SELECT id, sender_id, receiver_id, amount, memo, category, reverses_transaction_id, reversed_by_id, created_at
+   FROM (`+strings.Join(sides, " UNION ALL ")+`) t
+   ORDER BY created_at DESC, id DESC
+   LIMIT `+limit, args...,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return []model.Transaction{}, nil 
-		}
-		return nil, fmt.Errorf("failed to query transactions: %w", err)
+		return nil, nil, fmt.Errorf("failed to query transactions: %w", err)
 	}
 	defer rows.Close()
 
-	var transactions []model.Transaction
+	transactions := []model.Transaction{}
 	for rows.Next() {
 		var t model.Transaction
-		if err := rows.Scan(&t.ID, &t.SenderID, &t.ReceiverID, &t.Amount, &t.CreatedAt); err != nil {
-			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		var reverses, reversedBy sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.SenderID, &t.ReceiverID, &t.Amount, &t.Memo, &t.Category,
+			&reverses, &reversedBy, &t.CreatedAt); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan transaction: %w", err)
 		}
+		t.ReversesID = int(reverses.Int64)
+		t.ReversedByID = int(reversedBy.Int64)
 		transactions = append(transactions, t)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating transaction rows: %w", err)
+		return nil, nil, fmt.Errorf("error iterating transaction rows: %w", err)
+	}
+
+	if len(transactions) <= filter.Limit {
+		return transactions, nil, nil
+	}
+	transactions = transactions[:filter.Limit]
+	last := transactions[len(transactions)-1]
+	return transactions, &model.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
+}
+
+// GetOutgoingTotals sums the user's transfers sent after daySince and weekSince.
+// Reversals are not counted, since they are not the user's own spending.
+func (r *TransactionRepository) GetOutgoingTotals(ctx context.Context, senderID int, daySince, weekSince time.Time) (model.OutgoingTotals, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
 	}
 
-	return transactions, nil
+	var totals model.OutgoingTotals
+	err := queryRow(ctx,
+		`SELECT COALESCE(SUM(amount) FILTER (WHERE created_at > $2), 0),
+   COALESCE(SUM(amount), 0),
+   COUNT(*) FILTER (WHERE created_at > $2)
+   FROM transactions
+   WHERE sender_id = $1 AND reverses_transaction_id IS NULL AND created_at > $3`,
+		senderID, daySince, weekSince,
+	).Scan(&totals.DailyAmount, &totals.WeeklyAmount, &totals.DailyCount)
+	if err != nil {
+		return model.OutgoingTotals{}, fmt.Errorf("failed to sum outgoing transfers: %w", err)
+	}
+	return totals, nil
 }
 
-func (r *TransactionRepository) CreatePurchase(ctx context.Context, userID int, itemName string, price int) error {
+const purchaseColumns = `id, user_id, COALESCE(merch_item_id, 0), item_name, COALESCE(sku, ''), list_price, discount, price, status, purchased_at,
+   approved_at, ready_at, delivered_at, cancelled_at, refunded_at`
+
+// scanPurchase scans purchaseColumns followed by extra and also returns purchased_at
+// as a time, which cursors need.
+func scanPurchase(row interface{ Scan(...interface{}) error }) (model.Purchase, time.Time, error) {
+	var p model.Purchase
+	var purchasedAt time.Time
+	var approvedAt, readyAt, deliveredAt, cancelledAt, refundedAt sql.NullTime
+	if err := row.Scan(&p.ID, &p.UserID, &p.ItemID, &p.ItemName, &p.SKU, &p.ListPrice, &p.Discount, &p.Price, &p.Status, &purchasedAt,
+		&approvedAt, &readyAt, &deliveredAt, &cancelledAt, &refundedAt); err != nil {
+		return model.Purchase{}, time.Time{}, err
+	}
+	p.PurchasedAt = purchasedAt.Format(time.RFC3339)
+	p.ApprovedAt = formatNullTime(approvedAt)
+	p.ReadyAt = formatNullTime(readyAt)
+	p.DeliveredAt = formatNullTime(deliveredAt)
+	p.CancelledAt = formatNullTime(cancelledAt)
+	p.RefundedAt = formatNullTime(refundedAt)
+	return p, purchasedAt, nil
+}
+
+func formatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format(time.RFC3339)
+}
+
+// CreatePurchase records a purchase booked by the journal entry entryID, or not paid for if entryID is 0,
+// and returns its ID. p.SKU is empty for items bought without a variant, and a zero p.ListPrice
+// means the purchase had no discount.
+func (r *TransactionRepository) CreatePurchase(ctx context.Context, p model.Purchase, entryID int) (int, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	if p.ListPrice == 0 {
+		p.ListPrice = p.Price
+	}
+
+	var id int
+	err := queryRow(ctx,
+		`INSERT INTO purchases (user_id, merch_item_id, item_name, sku, list_price, discount, price, journal_entry_id)
+   VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8) RETURNING id`,
+		p.UserID, nullableID(p.ItemID), p.ItemName, p.SKU, p.ListPrice, p.Discount, p.Price, nullableID(entryID),
+	).Scan(&id)
+	return id, err
+}
+
+// SetPurchasesJournalEntry marks the purchases as paid by the journal entry.
+func (r *TransactionRepository) SetPurchasesJournalEntry(ctx context.Context, ids []int, entryID int) error {
 	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 	if r.tx != nil {
 		execContext = r.tx.ExecContext
@@ -84,13 +269,17 @@ func (r *TransactionRepository) CreatePurchase(ctx context.Context, userID int,
 	}
 
 	_, err := execContext(ctx,
-		"INSERT INTO purchases (user_id, item_name, price) VALUES ($1, $2, $3)",
-		userID, itemName, price,
+		"UPDATE purchases SET journal_entry_id = $1 WHERE id = ANY($2)", entryID, pq.Array(ids),
 	)
-	return err
+	if err != nil {
+		return fmt.Errorf("failed to set journal entry of purchases: %w", err)
+	}
+	return nil
 }
 
-func (r *TransactionRepository) GetPurchasesByUserID(ctx context.Context, userID int) ([]model.Purchase, error) {
+// GetPurchasesByUserID returns one page of the user's purchases, newest first, and the cursor
+// of the next page or nil if this is the last one.
+func (r *TransactionRepository) GetPurchasesByUserID(ctx context.Context, userID int, filter model.PurchaseFilter) ([]model.Purchase, *model.Cursor, error) {
 	var queryContext func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 	if r.tx != nil {
 		queryContext = r.tx.QueryContext
@@ -98,28 +287,145 @@ func (r *TransactionRepository) GetPurchasesByUserID(ctx context.Context, userID
 		queryContext = r.db.QueryContext
 	}
 
+	args := queryArgs{}
+	conditions := []string{"user_id = " + args.add(userID)}
+	if filter.ItemName != "" {
+		conditions = append(conditions, "item_name = "+args.add(filter.ItemName))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+args.add(filter.Status))
+	}
+	if filter.MinPrice > 0 {
+		conditions = append(conditions, "price >= "+args.add(filter.MinPrice))
+	}
+	if filter.MaxPrice > 0 {
+		conditions = append(conditions, "price <= "+args.add(filter.MaxPrice))
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "purchased_at >= "+args.add(*filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "purchased_at < "+args.add(*filter.To))
+	}
+	if filter.After != nil {
+		conditions = append(conditions,
+			"(purchased_at, id) < ("+args.add(filter.After.CreatedAt)+", "+args.add(filter.After.ID)+")")
+	}
+
 	rows, err := queryContext(ctx,
-		`SELECT id, user_id, item_name, price, purchased_at
+		`SELECT `+purchaseColumns+`
    FROM purchases
-   WHERE user_id = $1
-   ORDER BY purchased_at DESC`, userID,
+   WHERE `+strings.Join(conditions, " AND ")+`
+   ORDER BY purchased_at DESC, id DESC
+   LIMIT `+args.add(filter.Limit+1), args...,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return []model.Purchase{}, nil 
+		return nil, nil, fmt.Errorf("failed to query purchases: %w", err)
+	}
+	defer rows.Close()
+
+	purchases := []model.Purchase{}
+	var lastPurchasedAt time.Time
+	for rows.Next() {
+		p, purchasedAt, err := scanPurchase(rows)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan purchase: %w", err)
 		}
+		if len(purchases) < filter.Limit {
+			lastPurchasedAt = purchasedAt
+		}
+		purchases = append(purchases, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating purchase rows: %w", err)
+	}
+
+	if len(purchases) <= filter.Limit {
+		return purchases, nil, nil
+	}
+	purchases = purchases[:filter.Limit]
+	return purchases, &model.Cursor{CreatedAt: lastPurchasedAt, ID: purchases[len(purchases)-1].ID}, nil
+}
+
+// GetPurchaseForUpdate locks the purchase row.
+func (r *TransactionRepository) GetPurchaseForUpdate(ctx context.Context, id int) (model.Purchase, error) {
+	if r.tx == nil {
+		return model.Purchase{}, errors.New("row lock requires a transaction")
+	}
+
+	p, _, err := scanPurchase(r.tx.QueryRowContext(ctx,
+		"SELECT "+purchaseColumns+" FROM purchases WHERE id = $1 FOR UPDATE", id,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Purchase{}, ErrPurchaseNotFound
+		}
+		return model.Purchase{}, fmt.Errorf("failed to lock purchase: %w", err)
+	}
+	return p, nil
+}
+
+// orderStatusColumns maps an order status to the column that records when it was reached.
+var orderStatusColumns = map[string]string{
+	model.OrderStatusApproved:       "approved_at",
+	model.OrderStatusReadyForPickup: "ready_at",
+	model.OrderStatusDelivered:      "delivered_at",
+	model.OrderStatusCancelled:      "cancelled_at",
+}
+
+// SetPurchaseStatus moves the order to status and stamps the time of the transition.
+func (r *TransactionRepository) SetPurchaseStatus(ctx context.Context, id int, status string) (model.Purchase, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	column, ok := orderStatusColumns[status]
+	if !ok {
+		return model.Purchase{}, fmt.Errorf("unknown order status %q", status)
+	}
+
+	p, _, err := scanPurchase(queryRow(ctx,
+		`UPDATE purchases SET status = $1, `+column+` = CURRENT_TIMESTAMP
+   WHERE id = $2
+   RETURNING `+purchaseColumns,
+		status, id,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Purchase{}, ErrPurchaseNotFound
+		}
+		return model.Purchase{}, fmt.Errorf("failed to set purchase status: %w", err)
+	}
+	return p, nil
+}
+
+// ListPurchasesByStatus returns all orders in status, oldest first, so they can be worked through in order.
+func (r *TransactionRepository) ListPurchasesByStatus(ctx context.Context, status string) ([]model.Purchase, error) {
+	var queryContext func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	if r.tx != nil {
+		queryContext = r.tx.QueryContext
+	} else {
+		queryContext = r.db.QueryContext
+	}
+
+	rows, err := queryContext(ctx,
+		"SELECT "+purchaseColumns+" FROM purchases WHERE status = $1 ORDER BY purchased_at, id", status,
+	)
+	if err != nil {
 		return nil, fmt.Errorf("failed to query purchases: %w", err)
 	}
 	defer rows.Close()
 
-	var purchases []model.Purchase
+	purchases := []model.Purchase{}
 	for rows.Next() {
-		var p model.Purchase
-		var purchasedAt time.Time
-		if err := rows.Scan(&p.ID, &p.UserID, &p.ItemName, &p.Price, &purchasedAt); err != nil {
+		p, _, err := scanPurchase(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan purchase: %w", err)
 		}
-		p.PurchasedAt = purchasedAt.Format(time.RFC3339)
 		purchases = append(purchases, p)
 	}
 
@@ -129,3 +435,28 @@ func (r *TransactionRepository) GetPurchasesByUserID(ctx context.Context, userID
 
 	return purchases, nil
 }
+
+// MarkPurchaseRefunded links the purchase to the refund entry and returns the refund time.
+func (r *TransactionRepository) MarkPurchaseRefunded(ctx context.Context, id int, entryID int) (time.Time, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	var refundedAt time.Time
+	err := queryRow(ctx,
+		`UPDATE purchases SET refunded_at = CURRENT_TIMESTAMP, refund_journal_entry_id = $1
+   WHERE id = $2 AND refunded_at IS NULL
+   RETURNING refunded_at`,
+		nullableID(entryID), id,
+	).Scan(&refundedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, ErrPurchaseAlreadyRefunded
+		}
+		return time.Time{}, fmt.Errorf("failed to mark purchase refunded: %w", err)
+	}
+	return refundedAt, nil
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+)
+
+const (
+	transferLimitColumns     = "scope, max_per_transfer, max_daily_amount, max_weekly_amount, max_daily_count, updated_at"
+	userTransferLimitColumns = "user_id, max_per_transfer, max_daily_amount, max_weekly_amount, max_daily_count, updated_at"
+)
+
+var ErrTransferLimitsNotFound = errors.New("transfer limits not found")
+
+type TransferLimitRepository struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+func NewTransferLimitRepository(db *sql.DB) *TransferLimitRepository {
+	return &TransferLimitRepository{db: db}
+}
+
+func NewTransferLimitRepositoryWithTx(tx *sql.Tx) *TransferLimitRepository {
+	return &TransferLimitRepository{tx: tx}
+}
+
+func scanTransferLimits(row interface{ Scan(...interface{}) error }) (model.TransferLimits, error) {
+	var limits model.TransferLimits
+	return limits, scanLimitColumns(row, &limits, &limits.Scope)
+}
+
+func scanUserTransferLimits(row interface{ Scan(...interface{}) error }) (model.TransferLimits, error) {
+	var limits model.TransferLimits
+	return limits, scanLimitColumns(row, &limits, &limits.UserID)
+}
+
+// scanLimitColumns scans a row whose first column, the owner of the limits, goes to owner.
+func scanLimitColumns(row interface{ Scan(...interface{}) error }, limits *model.TransferLimits, owner interface{}) error {
+	var perTransfer, dailyAmount, weeklyAmount, dailyCount sql.NullInt64
+	err := row.Scan(owner, &perTransfer, &dailyAmount, &weeklyAmount, &dailyCount, &limits.UpdatedAt)
+	limits.MaxPerTransfer = nullableInt(perTransfer)
+	limits.MaxDailyAmount = nullableInt(dailyAmount)
+	limits.MaxWeeklyAmount = nullableInt(weeklyAmount)
+	limits.MaxDailyCount = nullableInt(dailyCount)
+	return err
+}
+
+func nullableInt(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	n := int(value.Int64)
+	return &n
+}
+
+// GetEffective returns the limits that apply to the user with role, resolved by
+// model.ResolveTransferLimits.
+func (r *TransferLimitRepository) GetEffective(ctx context.Context, userID int, role string) (model.TransferLimits, error) {
+	limits, err := r.list(ctx, scanTransferLimits,
+		"SELECT "+transferLimitColumns+" FROM transfer_limits WHERE scope IN ('global', $1)", role,
+	)
+	if err != nil {
+		return model.TransferLimits{}, err
+	}
+
+	var global, roleLimits model.TransferLimits
+	for _, l := range limits {
+		if l.Scope == model.LimitScopeGlobal {
+			global = l
+		} else {
+			roleLimits = l
+		}
+	}
+
+	userLimits, err := r.GetForUser(ctx, userID)
+	if err != nil && !errors.Is(err, ErrTransferLimitsNotFound) {
+		return model.TransferLimits{}, err
+	}
+
+	effective := model.ResolveTransferLimits(global, roleLimits, userLimits)
+	effective.Scope = role
+	effective.UserID = userID
+	return effective, nil
+}
+
+// List returns the global and role limits, followed by the limits of single users.
+func (r *TransferLimitRepository) List(ctx context.Context) ([]model.TransferLimits, error) {
+	limits, err := r.list(ctx, scanTransferLimits, "SELECT "+transferLimitColumns+" FROM transfer_limits ORDER BY scope")
+	if err != nil {
+		return nil, err
+	}
+	userLimits, err := r.list(ctx, scanUserTransferLimits, "SELECT "+userTransferLimitColumns+" FROM user_transfer_limits ORDER BY user_id")
+	if err != nil {
+		return nil, err
+	}
+	return append(limits, userLimits...), nil
+}
+
+func (r *TransferLimitRepository) GetForUser(ctx context.Context, userID int) (model.TransferLimits, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	limits, err := scanUserTransferLimits(queryRow(ctx,
+		"SELECT "+userTransferLimitColumns+" FROM user_transfer_limits WHERE user_id = $1", userID,
+	))
+	if err == sql.ErrNoRows {
+		return model.TransferLimits{}, ErrTransferLimitsNotFound
+	} else if err != nil {
+		return model.TransferLimits{}, fmt.Errorf("failed to get transfer limits of user: %w", err)
+	}
+	return limits, nil
+}
+
+func (r *TransferLimitRepository) list(ctx context.Context, scan func(interface{ Scan(...interface{}) error }) (model.TransferLimits, error), query string, args ...interface{}) ([]model.TransferLimits, error) {
+	var queryContext func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	if r.tx != nil {
+		queryContext = r.tx.QueryContext
+	} else {
+		queryContext = r.db.QueryContext
+	}
+
+	rows, err := queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transfer limits: %w", err)
+	}
+	defer rows.Close()
+
+	limits := []model.TransferLimits{}
+	for rows.Next() {
+		l, err := scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transfer limits: %w", err)
+		}
+		limits = append(limits, l)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transfer limit rows: %w", err)
+	}
+
+	return limits, nil
+}
+
+// Save replaces the limits of limits.Scope.
+func (r *TransferLimitRepository) Save(ctx context.Context, limits model.TransferLimits) (model.TransferLimits, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	saved, err := scanTransferLimits(queryRow(ctx,
+		`INSERT INTO transfer_limits (scope, max_per_transfer, max_daily_amount, max_weekly_amount, max_daily_count)
+   VALUES ($1, $2, $3, $4, $5)
+   ON CONFLICT (scope) DO UPDATE SET
+   max_per_transfer = EXCLUDED.max_per_transfer,
+   max_daily_amount = EXCLUDED.max_daily_amount,
+   max_weekly_amount = EXCLUDED.max_weekly_amount,
+   max_daily_count = EXCLUDED.max_daily_count,
+   updated_at = CURRENT_TIMESTAMP
+   RETURNING `+transferLimitColumns,
+		limits.Scope, limits.MaxPerTransfer, limits.MaxDailyAmount, limits.MaxWeeklyAmount, limits.MaxDailyCount,
+	))
+	if err != nil {
+		return model.TransferLimits{}, fmt.Errorf("failed to save transfer limits: %w", err)
+	}
+	return saved, nil
+}
+
+// SaveForUser replaces the limits of the user limits.UserID.
+func (r *TransferLimitRepository) SaveForUser(ctx context.Context, limits model.TransferLimits) (model.TransferLimits, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	saved, err := scanUserTransferLimits(queryRow(ctx,
+		`INSERT INTO user_transfer_limits (user_id, max_per_transfer, max_daily_amount, max_weekly_amount, max_daily_count)
+   VALUES ($1, $2, $3, $4, $5)
+   ON CONFLICT (user_id) DO UPDATE SET
+   max_per_transfer = EXCLUDED.max_per_transfer,
+   max_daily_amount = EXCLUDED.max_daily_amount,
+   max_weekly_amount = EXCLUDED.max_weekly_amount,
+   max_daily_count = EXCLUDED.max_daily_count,
+   updated_at = CURRENT_TIMESTAMP
+   RETURNING `+userTransferLimitColumns,
+		limits.UserID, limits.MaxPerTransfer, limits.MaxDailyAmount, limits.MaxWeeklyAmount, limits.MaxDailyCount,
+	))
+	if err != nil {
+		return model.TransferLimits{}, fmt.Errorf("failed to save transfer limits of user: %w", err)
+	}
+	return saved, nil
+}
+
+// DeleteForUser removes the limits of userID, so the limits of their role apply again.
+func (r *TransferLimitRepository) DeleteForUser(ctx context.Context, userID int) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	res, err := execContext(ctx, "DELETE FROM user_transfer_limits WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete transfer limits of user: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows count after delete: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrTransferLimitsNotFound
+	}
+	return nil
+}
@@ -5,11 +5,18 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
 
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
 )
 
-var ErrUserNotFound = errors.New("user not found")
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUsernameTaken = errors.New("username already taken")
+)
 
 type UserRepository struct {
 	db *sql.DB
@@ -34,8 +41,8 @@ func (r *UserRepository) Create(ctx context.Context, userID int) (*model.User, e
 
 	var user model.User
 	err := execContext(ctx,
-		"INSERT INTO users(id, coins) VALUES($1, 1000) ON CONFLICT (id) DO NOTHING RETURNING id, coins", userID,
-	).Scan(&user.ID, &user.Coins)
+		"INSERT INTO users(id, coins) VALUES($1, 1000) ON CONFLICT (id) DO NOTHING RETURNING id, coins, role", userID,
+	).Scan(&user.ID, &user.Coins, &user.Role)
 
 	if err == sql.ErrNoRows {
 		return r.GetByID(ctx, userID)
@@ -59,40 +66,75 @@ func (r *UserRepository) GetByID(ctx context.Context, id int) (*model.User, erro
 	}
 
 	var user model.User
+	var username sql.NullString
 	err := queryRow(ctx,
-		"SELECT id, coins FROM users WHERE id = $1", id,
-	).Scan(&user.ID, &user.Coins)
+		"SELECT id, coins, role, username FROM users WHERE id = $1", id,
+	).Scan(&user.ID, &user.Coins, &user.Role, &username)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user by ID: %w", err)
 	}
+	user.Username = username.String
 	return &user, nil
 }
 
-func (r *UserRepository) UpdateCoins(ctx context.Context, id int, newCoins int) error {
-	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
-	if r.tx != nil {
-		execContext = r.tx.ExecContext
-	} else {
-		execContext = r.db.ExecContext
+// GetByIDForUpdate locks the user row until the transaction ends.
+func (r *UserRepository) GetByIDForUpdate(ctx context.Context, id int) (*model.User, error) {
+	if r.tx == nil {
+		return nil, errors.New("row lock requires a transaction")
 	}
 
-	res, err := execContext(ctx,
-		"UPDATE users SET coins = $1 WHERE id = $2", newCoins, id,
-	)
+	var user model.User
+	var username sql.NullString
+	err := r.tx.QueryRowContext(ctx,
+		"SELECT id, coins, role, username FROM users WHERE id = $1 FOR UPDATE", id,
+	).Scan(&user.ID, &user.Coins, &user.Role, &username)
 	if err != nil {
-		return fmt.Errorf("failed to update user coins: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to lock user: %w", err)
 	}
-	rowsAffected, err := res.RowsAffected()
+	user.Username = username.String
+	return &user, nil
+}
+
+// LockUsers locks the user rows in ascending ID order, so two transactions locking
+// the same users can never deadlock on each other.
+func (r *UserRepository) LockUsers(ctx context.Context, ids ...int) (map[int]*model.User, error) {
+	users, err := r.LockExistingUsers(ctx, ids...)
 	if err != nil {
-		return fmt.Errorf("failed to get affected rows count after update: %w", err)
+		return nil, err
 	}
-	if rowsAffected == 0 {
-		return ErrUserNotFound
+	for _, id := range ids {
+		if _, ok := users[id]; !ok {
+			return nil, ErrUserNotFound
+		}
 	}
-	return nil
+	return users, nil
+}
+
+// LockExistingUsers locks the users like LockUsers but leaves IDs without a user out of the result.
+func (r *UserRepository) LockExistingUsers(ctx context.Context, ids ...int) (map[int]*model.User, error) {
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+
+	users := make(map[int]*model.User, len(sorted))
+	for _, id := range sorted {
+		if _, locked := users[id]; locked {
+			continue
+		}
+		user, err := r.GetByIDForUpdate(ctx, id)
+		if errors.Is(err, ErrUserNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		users[id] = user
+	}
+	return users, nil
 }
 
 func (r *UserRepository) GetCoins(ctx context.Context, id int) (int, error) {
@@ -115,3 +157,197 @@ func (r *UserRepository) GetCoins(ctx context.Context, id int) (int, error) {
 	}
 	return coins, nil
 }
+
+// GetBalance returns the user's ledger balance and the part of it reserved by holds.
+func (r *UserRepository) GetBalance(ctx context.Context, id int) (int, int, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	var coins, held int
+	err := queryRow(ctx,
+		"SELECT coins, "+heldCoinsSQL("$1")+" FROM users WHERE id = $1", id,
+	).Scan(&coins, &held)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, 0, ErrUserNotFound
+		}
+		return 0, 0, fmt.Errorf("failed to get user balance: %w", err)
+	}
+	return coins, held, nil
+}
+
+func (r *UserRepository) CreateWithCredentials(ctx context.Context, username, passwordHash string) (*model.User, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	user := model.User{Username: username}
+	err := queryRow(ctx,
+		`INSERT INTO users (coins, username, password_hash, password_changed_at)
+   VALUES (1000, $1, $2, CURRENT_TIMESTAMP)
+   RETURNING id, coins, role`, username, passwordHash,
+	).Scan(&user.ID, &user.Coins, &user.Role)
+	if err != nil {
+		// Any other unique violation, such as an ID taken by a row inserted with an explicit ID,
+		// is not the client's fault.
+		if isUniqueViolationOf(err, usernameUniqueConstraint) {
+			return nil, ErrUsernameTaken
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) GetCredentialsByUsername(ctx context.Context, username string) (*model.Credentials, error) {
+	return r.getCredentials(ctx, "username = $1", username)
+}
+
+func (r *UserRepository) GetCredentialsByID(ctx context.Context, id int) (*model.Credentials, error) {
+	return r.getCredentials(ctx, "id = $1", id)
+}
+
+func (r *UserRepository) getCredentials(ctx context.Context, where string, arg interface{}) (*model.Credentials, error) {
+	var queryRow func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	if r.tx != nil {
+		queryRow = r.tx.QueryRowContext
+	} else {
+		queryRow = r.db.QueryRowContext
+	}
+
+	var creds model.Credentials
+	var username, passwordHash sql.NullString
+	var lockedUntil sql.NullTime
+	err := queryRow(ctx,
+		`SELECT id, username, password_hash, failed_login_attempts, locked_until
+   FROM users
+   WHERE `+where, arg,
+	).Scan(&creds.UserID, &username, &passwordHash, &creds.FailedLoginAttempts, &lockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user credentials: %w", err)
+	}
+	creds.Username = username.String
+	creds.PasswordHash = passwordHash.String
+	if lockedUntil.Valid {
+		creds.LockedUntil = &lockedUntil.Time
+	}
+	return &creds, nil
+}
+
+// RecordFailedLogin counts a failed attempt. Reaching maxAttempts locks the account for lockout
+// and starts the count again.
+func (r *UserRepository) RecordFailedLogin(ctx context.Context, id int, maxAttempts int, lockout time.Duration) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		`UPDATE users SET
+     locked_until = CASE WHEN failed_login_attempts + 1 >= $1
+       THEN CURRENT_TIMESTAMP + make_interval(secs => $2) ELSE locked_until END,
+     failed_login_attempts = CASE WHEN failed_login_attempts + 1 >= $1
+       THEN 0 ELSE failed_login_attempts + 1 END
+   WHERE id = $3`,
+		maxAttempts, lockout.Seconds(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record failed login: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepository) ResetFailedLogins(ctx context.Context, id int) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		"UPDATE users SET failed_login_attempts = 0, locked_until = NULL WHERE id = $1", id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reset failed logins: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	res, err := execContext(ctx,
+		"UPDATE users SET password_hash = $1, password_changed_at = CURRENT_TIMESTAMP WHERE id = $2",
+		passwordHash, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows count after update: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdateRole(ctx context.Context, id int, role string) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	res, err := execContext(ctx,
+		"UPDATE users SET role = $1 WHERE id = $2", role, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update user role: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows count after update: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// PromoteToAdmin grants the admin role to the existing users among ids.
+func (r *UserRepository) PromoteToAdmin(ctx context.Context, ids []int) error {
+	var execContext func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	if r.tx != nil {
+		execContext = r.tx.ExecContext
+	} else {
+		execContext = r.db.ExecContext
+	}
+
+	_, err := execContext(ctx,
+		"UPDATE users SET role = 'admin' WHERE id = ANY($1)", pq.Array(ids),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to promote users to admin: %w", err)
+	}
+	return nil
+}
@@ -2,41 +2,406 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log/slog"
+	"regexp"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
 
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/metrics"
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrAccountLocked       = errors.New("account locked")
+	ErrUsernameTaken       = errors.New("username already taken")
+	ErrInvalidUsername     = errors.New("invalid username")
+	ErrWeakPassword        = errors.New("password too weak")
+	ErrUserIDLoginDisabled = errors.New("login by user ID is disabled")
+	ErrInvalidRole         = errors.New("invalid role")
+	ErrUserNotFound        = errors.New("user not found")
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,32}$`)
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores everything after 72 bytes.
+	maxPasswordLength = 72
+)
+
+// dummyPasswordHash is compared against when the username does not exist,
+// so unknown and known usernames take the same time to reject.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+type AuthOptions struct {
+	JWTSecret       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// AllowUserIDLogin enables the passwordless login by user ID. It is meant for local development only.
+	AllowUserIDLogin bool
+	MaxFailedLogins  int
+	LockoutDuration  time.Duration
+}
 
 type AuthService struct {
-	userRepo  *repository.UserRepository
-	jwtSecret string
+	userRepo    *repository.UserRepository
+	sessionRepo *repository.SessionRepository
+	db          *sql.DB
+	opts        AuthOptions
+	logger      *slog.Logger
+}
+
+func NewAuthService(userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository, db *sql.DB, opts AuthOptions, logger *slog.Logger) *AuthService {
+	return &AuthService{
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		db:          db,
+		opts:        opts,
+		logger:      logger,
+	}
+}
+
+func (s *AuthService) Register(ctx context.Context, username, password string) (*model.TokenPair, *model.User, error) {
+	if !usernamePattern.MatchString(username) {
+		return nil, nil, ErrInvalidUsername
+	}
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return nil, nil, ErrWeakPassword
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user, err := s.userRepo.CreateWithCredentials(ctx, username, string(passwordHash))
+	if errors.Is(err, repository.ErrUsernameTaken) {
+		return nil, nil, ErrUsernameTaken
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to register user: %w", err)
+	}
+
+	tokens, err := s.startSession(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, user, nil
+}
+
+func (s *AuthService) LoginWithPassword(ctx context.Context, username, password string) (*model.TokenPair, *model.User, error) {
+	creds, err := s.userRepo.GetCredentialsByUsername(ctx, username)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		metrics.FailedLogin(metrics.LoginUnknownUser)
+		return nil, nil, ErrInvalidCredentials
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to get credentials: %w", err)
+	}
+
+	if err := s.verifyPassword(ctx, creds, password); err != nil {
+		if errors.Is(err, ErrAccountLocked) {
+			metrics.FailedLogin(metrics.LoginLocked)
+		} else if errors.Is(err, ErrInvalidCredentials) {
+			metrics.FailedLogin(metrics.LoginWrongPassword)
+		}
+		return nil, nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, creds.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	user.Username = creds.Username
+
+	tokens, err := s.startSession(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, user, nil
+}
+
+// ChangePassword revokes every session of the user and opens a new one for the caller.
+func (s *AuthService) ChangePassword(ctx context.Context, userID int, currentPassword, newPassword string) (*model.TokenPair, error) {
+	if len(newPassword) < minPasswordLength || len(newPassword) > maxPasswordLength {
+		return nil, ErrWeakPassword
+	}
+
+	creds, err := s.userRepo.GetCredentialsByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials: %w", err)
+	}
+
+	if err := s.verifyPassword(ctx, creds, currentPassword); err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := s.userRepo.UpdatePassword(ctx, userID, string(passwordHash)); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.sessionRepo.RevokeUserSessions(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.startSession(ctx, userID)
 }
 
-func NewAuthService(userRepo *repository.UserRepository, jwtSecret string) *AuthService {
-	return &AuthService{userRepo: userRepo, jwtSecret: jwtSecret}
+// verifyPassword enforces the lockout policy around the password comparison.
+func (s *AuthService) verifyPassword(ctx context.Context, creds *model.Credentials, password string) error {
+	if creds.LockedUntil != nil && time.Now().Before(*creds.LockedUntil) {
+		return ErrAccountLocked
+	}
+
+	if creds.PasswordHash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(creds.PasswordHash), []byte(password)); err != nil {
+		if err := s.userRepo.RecordFailedLogin(ctx, creds.UserID, s.opts.MaxFailedLogins, s.opts.LockoutDuration); err != nil {
+			return err
+		}
+		return ErrInvalidCredentials
+	}
+
+	if creds.FailedLoginAttempts > 0 || creds.LockedUntil != nil {
+		if err := s.userRepo.ResetFailedLogins(ctx, creds.UserID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (s *AuthService) Login(ctx context.Context, userID int) (string, *model.User, error) {
+// Login is the passwordless login by user ID that creates the user on first use.
+func (s *AuthService) Login(ctx context.Context, userID int) (*model.TokenPair, *model.User, error) {
+	if !s.opts.AllowUserIDLogin {
+		return nil, nil, ErrUserIDLoginDisabled
+	}
+
 	user, err := s.userRepo.Create(ctx, userID)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to login or create user: %w", err)
+		return nil, nil, fmt.Errorf("failed to login or create user: %w", err)
 	}
 
+	tokens, err := s.startSession(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, user, nil
+}
+
+// startSession opens a new session (refresh token family) for the user and issues its first token pair.
+func (s *AuthService) startSession(ctx context.Context, userID int) (*model.TokenPair, error) {
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil || err != nil {
+			rollback(ctx, s.logger, tx, "AuthService.startSession", err, p)
+		}
+	}()
+
+	sessionRepoTx := repository.NewSessionRepositoryWithTx(tx)
+	if err = sessionRepoTx.CreateSession(ctx, sessionID, userID); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.issueTokens(ctx, tx, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return tokens, nil
+}
+
+// Refresh rotates the refresh token. Presenting an already used token revokes its whole session,
+// since it means the token has leaked.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*model.TokenPair, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil || err != nil {
+			rollback(ctx, s.logger, tx, "AuthService.Refresh", err, p)
+		}
+	}()
+
+	sessionRepoTx := repository.NewSessionRepositoryWithTx(tx)
+	token, err := sessionRepoTx.GetRefreshTokenForUpdate(ctx, hashToken(refreshToken))
+	if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+		err = ErrInvalidRefreshToken
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	if token.Revoked || time.Now().After(token.ExpiresAt) {
+		err = ErrInvalidRefreshToken
+		return nil, err
+	}
+
+	if token.UsedAt != nil {
+		if err = sessionRepoTx.RevokeSession(ctx, token.SessionID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		s.logger.WarnContext(ctx, "refresh token reuse detected, session revoked", "session_id", token.SessionID, "user_id", token.UserID)
+		return nil, ErrRefreshTokenReused
+	}
+
+	if err = sessionRepoTx.MarkRefreshTokenUsed(ctx, token.ID); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.issueTokens(ctx, tx, token.SessionID, token.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return tokens, nil
+}
+
+// Logout revokes the session the refresh token belongs to.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil || err != nil {
+			rollback(ctx, s.logger, tx, "AuthService.Logout", err, p)
+		}
+	}()
+
+	sessionRepoTx := repository.NewSessionRepositoryWithTx(tx)
+	token, err := sessionRepoTx.GetRefreshTokenForUpdate(ctx, hashToken(refreshToken))
+	if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+		err = ErrInvalidRefreshToken
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if err = sessionRepoTx.RevokeSession(ctx, token.SessionID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *AuthService) RevokeUserSessions(ctx context.Context, userID int) (int, error) {
+	revoked, err := s.sessionRepo.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions for user %d: %w", userID, err)
+	}
+	return revoked, nil
+}
+
+// SetRole changes the user's role and revokes the user's sessions, so tokens with the old role stop working.
+func (s *AuthService) SetRole(ctx context.Context, userID int, role string) error {
+	if !model.IsValidRole(role) {
+		return ErrInvalidRole
+	}
+
+	err := s.userRepo.UpdateRole(ctx, userID, role)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := s.sessionRepo.RevokeUserSessions(ctx, userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *AuthService) IsSessionActive(ctx context.Context, sessionID string, userID int) (bool, error) {
+	return s.sessionRepo.IsSessionActive(ctx, sessionID, userID)
+}
+
+// issueTokens signs an access token carrying the user's current role and stores a new refresh token.
+func (s *AuthService) issueTokens(ctx context.Context, tx *sql.Tx, sessionID string, userID int) (*model.TokenPair, error) {
+	user, err := repository.NewUserRepositoryWithTx(tx).GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.opts.AccessTokenTTL)
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userID":   user.ID,
-		"exp":      time.Now().Add(24 * time.Hour).Unix(),
-		"issuedAt": time.Now().Unix(),
+		"userID":   userID,
+		"role":     user.Role,
+		"sid":      sessionID,
+		"exp":      expiresAt.Unix(),
+		"issuedAt": now.Unix(),
 	})
 
-	tokenString, err := token.SignedString([]byte(s.jwtSecret))
+	accessToken, err := token.SignedString([]byte(s.opts.JWTSecret))
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to sign JWT token: %w", err)
+		return nil, fmt.Errorf("failed to sign JWT token: %w", err)
 	}
 
-	return tokenString, user, nil
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	if err := repository.NewSessionRepositoryWithTx(tx).CreateRefreshToken(ctx, sessionID, hashToken(refreshToken), now.Add(s.opts.RefreshTokenTTL)); err != nil {
+		return nil, err
+	}
+
+	return &model.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken is used to store refresh tokens; they are random enough that a plain SHA-256 suffices.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
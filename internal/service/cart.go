@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/metrics"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+var (
+	ErrCartEmpty        = errors.New("cart is empty")
+	ErrCartItemNotFound = errors.New("cart item not found")
+)
+
+type CartService struct {
+	cartRepo  *repository.CartRepository
+	merchRepo *repository.MerchRepository
+	db        *sql.DB
+	logger    *slog.Logger
+}
+
+func NewCartService(cartRepo *repository.CartRepository, merchRepo *repository.MerchRepository, db *sql.DB, logger *slog.Logger) *CartService {
+	return &CartService{
+		cartRepo:  cartRepo,
+		merchRepo: merchRepo,
+		db:        db,
+		logger:    logger,
+	}
+}
+
+func (s *CartService) GetCart(ctx context.Context, userID int) (*model.Cart, error) {
+	items, err := s.cartRepo.ListItems(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart for user %d: %w", userID, err)
+	}
+	return newCart(items), nil
+}
+
+func (s *CartService) AddItem(ctx context.Context, userID int, itemName string, quantity int) (*model.Cart, error) {
+	if quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	merchItem, err := s.merchRepo.GetMerchItemByName(ctx, itemName)
+	if errors.Is(err, repository.ErrMerchItemNotFound) {
+		return nil, ErrMerchNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get merch item: %w", err)
+	}
+	// The cart has no notion of variants, so items sold in sizes or colors are bought one at a time.
+	if _, err := resolveVariant(ctx, s.merchRepo, merchItem, ""); err != nil {
+		return nil, err
+	}
+
+	if err := s.cartRepo.AddItem(ctx, userID, merchItem.ID, quantity); err != nil {
+		return nil, fmt.Errorf("failed to add item to cart: %w", err)
+	}
+	return s.GetCart(ctx, userID)
+}
+
+func (s *CartService) RemoveItem(ctx context.Context, userID int, itemName string) (*model.Cart, error) {
+	items, err := s.cartRepo.ListItems(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart for user %d: %w", userID, err)
+	}
+
+	// Look the item up in the cart rather than the catalog, so deactivated items can still be removed.
+	for _, item := range items {
+		if item.ItemName != itemName {
+			continue
+		}
+		err := s.cartRepo.RemoveItem(ctx, userID, item.ItemID)
+		if errors.Is(err, repository.ErrCartItemNotFound) {
+			return nil, ErrCartItemNotFound
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to remove item from cart: %w", err)
+		}
+		return s.GetCart(ctx, userID)
+	}
+	return nil, ErrCartItemNotFound
+}
+
+// Checkout buys everything in the cart in one transaction: either every item is bought or none.
+// Each unit is priced like a direct purchase, with automatic promotions and the optional promoCode.
+// The code is applied to every unit it gives the best discount on, and it is an error if that is none.
+func (s *CartService) Checkout(ctx context.Context, userID int, promoCode string) (*model.Cart, error) {
+	var cart *model.Cart
+	err := runWithRetry(ctx, func() error {
+		var err error
+		cart, err = s.checkout(ctx, userID, promoCode)
+		return err
+	})
+	if errors.Is(err, ErrInsufficientFunds) {
+		metrics.InsufficientFunds(metrics.OperationPurchase)
+	}
+	return cart, err
+}
+
+func (s *CartService) checkout(ctx context.Context, userID int, promoCode string) (*model.Cart, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil || err != nil {
+			rollback(ctx, s.logger, tx, "CartService.checkout", err, p)
+		}
+	}()
+
+	cartRepoTx := repository.NewCartRepositoryWithTx(tx)
+	merchRepoTx := repository.NewMerchRepositoryWithTx(tx)
+	userRepoTx := repository.NewUserRepositoryWithTx(tx)
+	transactionRepoTx := repository.NewTransactionRepositoryWithTx(tx)
+	promotionRepoTx := repository.NewPromotionRepositoryWithTx(tx)
+
+	items, err := cartRepoTx.ListItemsForUpdate(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart: %w", err)
+	}
+	if len(items) == 0 {
+		err = ErrCartEmpty
+		return nil, err
+	}
+
+	cart := newCart(items)
+	merchItems := make([]model.Merch, len(cart.Items))
+	for i, item := range cart.Items {
+		if !item.Available {
+			err = ErrMerchNotFound
+			return nil, err
+		}
+		if merchItems[i], err = merchRepoTx.GetMerchItemByID(ctx, item.ItemID); err != nil {
+			return nil, fmt.Errorf("failed to get merch item: %w", err)
+		}
+		// Variants may have been added since the item was put in the cart, which cannot say which one to buy.
+		if _, err = resolveVariant(ctx, merchRepoTx, merchItems[i], ""); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err = userRepoTx.GetByIDForUpdate(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	// Promotions are redeemed unit by unit, so each unit sees the caps used up by the ones before.
+	// The journal entry can only be posted once the total is known, and is attached afterwards.
+	codeUsed := promoCode == ""
+	var codeErr error
+	var paidIDs []int
+	var purchases []model.Purchase
+	total := 0
+	for i, item := range cart.Items {
+		if err = merchRepoTx.DecrementStock(ctx, item.ItemID, item.Quantity); errors.Is(err, repository.ErrMerchOutOfStock) {
+			err = ErrOutOfStock
+			return nil, err
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decrement stock: %w", err)
+		}
+
+		// purchases has no quantity column, so every unit is its own row.
+		for u := 0; u < item.Quantity; u++ {
+			var redemptions []model.Redemption
+			var discount int
+			var unitCodeErr error
+			redemptions, discount, unitCodeErr, err = applyCheckoutPromotions(ctx, tx, userID, merchItems[i], item.Price, promoCode)
+			if err != nil {
+				return nil, err
+			}
+			if unitCodeErr != nil {
+				codeErr = unitCodeErr
+			} else {
+				codeUsed = true
+			}
+
+			purchase := model.Purchase{
+				UserID:    userID,
+				ItemID:    item.ItemID,
+				ItemName:  item.ItemName,
+				ListPrice: item.Price,
+				Discount:  discount,
+				Price:     item.Price - discount,
+			}
+			if purchase.ID, err = transactionRepoTx.CreatePurchase(ctx, purchase, 0); err != nil {
+				return nil, fmt.Errorf("failed to record purchase: %w", err)
+			}
+			for _, redemption := range redemptions {
+				if err = promotionRepoTx.Redeem(ctx, userID, purchase.ID, redemption); err != nil {
+					return nil, err
+				}
+			}
+
+			// A unit discounted down to nothing moves no coins and stays without a journal entry.
+			if purchase.Price > 0 {
+				paidIDs = append(paidIDs, purchase.ID)
+			}
+			purchases = append(purchases, purchase)
+			total += purchase.Price
+		}
+	}
+	if !codeUsed {
+		err = codeErr
+		return nil, err
+	}
+
+	available, err := availableCoins(ctx, userRepoTx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if available < total {
+		err = ErrInsufficientFunds
+		return nil, err
+	}
+	if total > 0 {
+		var entryID int
+		entryID, err = postPurchase(ctx, tx, userID, total, fmt.Sprintf("checkout by user %d", userID))
+		if errors.Is(err, repository.ErrNegativeBalance) {
+			err = ErrInsufficientFunds
+			return nil, err
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to post purchase: %w", err)
+		}
+		if err = transactionRepoTx.SetPurchasesJournalEntry(ctx, paidIDs, entryID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = cartRepoTx.Clear(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, purchase := range purchases {
+		metrics.PurchaseCompleted(purchase.ItemName, purchase.Price)
+	}
+	cart.Discount = cart.Total - total
+	cart.Total = total
+	return cart, nil
+}
+
+// applyCheckoutPromotions prices one unit of a checkout like a direct purchase with code. If the
+// code cannot be used on this unit, the unit gets the automatic promotions only and the reason is
+// returned as codeErr; an invalid code is an error for the whole checkout.
+func applyCheckoutPromotions(ctx context.Context, tx *sql.Tx, userID int, item model.Merch, price int, code string) (redemptions []model.Redemption, discount int, codeErr error, err error) {
+	if code != "" {
+		redemptions, discount, err = applyPromotions(ctx, tx, userID, item, price, code)
+		if !errors.Is(err, ErrPromoCodeNotApplicable) && !errors.Is(err, ErrPromoCodeExhausted) {
+			return redemptions, discount, nil, err
+		}
+		codeErr = err
+	}
+	redemptions, discount, err = applyPromotions(ctx, tx, userID, item, price, "")
+	return redemptions, discount, codeErr, err
+}
+
+func newCart(items []model.CartItem) *model.Cart {
+	cart := &model.Cart{Items: items}
+	for _, item := range items {
+		cart.Total += item.Subtotal
+	}
+	return cart
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+func newTestCartService(db *sql.DB) *CartService {
+	return NewCartService(repository.NewCartRepository(db), repository.NewMerchRepository(db), db, discardLogger())
+}
+
+func TestCheckoutRejectsItemThatGainedVariants(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	cartService := newTestCartService(db)
+	merchService := newTestMerchService(db)
+	buyer := createTestUser(t, db, 1)
+
+	if _, err := cartService.AddItem(ctx, buyer, "t-shirt", 1); err != nil {
+		t.Fatalf("AddItem() error = %v", err)
+	}
+	item, err := repository.NewMerchRepository(db).GetMerchItemByName(ctx, "t-shirt")
+	if err != nil {
+		t.Fatalf("failed to get t-shirt: %v", err)
+	}
+	if _, err := merchService.CreateVariant(ctx, item.ID, model.MerchVariant{SKU: "TSHIRT-M", Size: "M"}); err != nil {
+		t.Fatalf("CreateVariant() error = %v", err)
+	}
+
+	if _, err := cartService.Checkout(ctx, buyer, ""); !errors.Is(err, ErrVariantRequired) {
+		t.Errorf("Checkout() error = %v, want %v", err, ErrVariantRequired)
+	}
+	if got := userCoins(t, db, buyer); got != 1000 {
+		t.Errorf("buyer has %d coins, want 1000", got)
+	}
+	cart, err := cartService.GetCart(ctx, buyer)
+	if err != nil || len(cart.Items) != 1 {
+		t.Errorf("GetCart() = %+v, %v, want the t-shirt still in the cart", cart, err)
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/lib/pq"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/migrations"
+)
+
+// testDSNEnv names the variable holding the DSN of a Postgres database for tests that need one,
+// e.g. "host=localhost user=postgres password=postgres dbname=avito_merch_test sslmode=disable".
+// The tests drop everything in its public schema, so never point it at a database whose data matters.
+const testDSNEnv = "TEST_DATABASE_DSN"
+
+// openTestDB returns a database with an empty schema at the latest migration.
+// The test is skipped if TEST_DATABASE_DSN is not set.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public"); err != nil {
+		t.Fatalf("failed to reset test database: %v", err)
+	}
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		t.Fatalf("failed to create migration driver: %v", err)
+	}
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		t.Fatalf("failed to read migrations: %v", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", src, "test", driver)
+	if err != nil {
+		t.Fatalf("failed to create migration instance: %v", err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+	return db
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// createTestUser inserts a user with the default 1000 coins and returns its ID.
+func createTestUser(t *testing.T, db *sql.DB, id int) int {
+	t.Helper()
+	user, err := repository.NewUserRepository(db).Create(context.Background(), id)
+	if err != nil {
+		t.Fatalf("failed to create user %d: %v", id, err)
+	}
+	return user.ID
+}
+
+func userCoins(t *testing.T, db *sql.DB, id int) int {
+	t.Helper()
+	coins, err := repository.NewUserRepository(db).GetCoins(context.Background(), id)
+	if err != nil {
+		t.Fatalf("failed to get coins of user %d: %v", id, err)
+	}
+	return coins
+}
+
+// checkBooks fails the test if any entry is unbalanced or any cached balance differs from the ledger.
+func checkBooks(t *testing.T, db *sql.DB) *model.LedgerReport {
+	t.Helper()
+	report, err := NewLedgerService(repository.NewLedgerRepository(db)).CheckBooks(context.Background())
+	if err != nil {
+		t.Fatalf("failed to check books: %v", err)
+	}
+	if report.TotalPostings != 0 {
+		t.Errorf("postings sum to %d, want 0", report.TotalPostings)
+	}
+	if len(report.UnbalancedEntries) > 0 {
+		t.Errorf("unbalanced entries: %v", report.UnbalancedEntries)
+	}
+	if len(report.BalanceMismatches) > 0 {
+		t.Errorf("users.coins differs from the ledger: %+v", report.BalanceMismatches)
+	}
+	return report
+}
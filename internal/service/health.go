@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+// schedulerStaleAfter is how many poll intervals the scheduler may miss before it counts as stuck.
+const schedulerStaleAfter = 3
+
+type HealthService struct {
+	healthRepo       *repository.HealthRepository
+	scheduleService  *ScheduleService
+	migrationVersion uint
+	timeout          time.Duration
+	shuttingDown     atomic.Bool
+}
+
+// NewHealthService checks readiness against migrationVersion, the latest migration shipped with
+// the binary. Every check that talks to the database is bounded by timeout.
+func NewHealthService(healthRepo *repository.HealthRepository, scheduleService *ScheduleService, migrationVersion uint, timeout time.Duration) *HealthService {
+	return &HealthService{
+		healthRepo:       healthRepo,
+		scheduleService:  scheduleService,
+		migrationVersion: migrationVersion,
+		timeout:          timeout,
+	}
+}
+
+// ShutDown makes readiness fail from now on, so the instance is drained before it stops.
+func (s *HealthService) ShutDown() {
+	s.shuttingDown.Store(true)
+}
+
+// Readiness runs every check and reports whether the instance can take traffic.
+// Latencies are included if verbose is set.
+func (s *HealthService) Readiness(ctx context.Context, verbose bool) model.HealthReport {
+	report := model.HealthReport{Status: model.HealthStatusOK, Checks: map[string]model.HealthCheck{}}
+
+	checks := []struct {
+		name  string
+		check func(ctx context.Context) (string, error)
+	}{
+		{"shutdown", s.checkShutdown},
+		{"database", s.checkDatabase},
+		{"migrations", s.checkMigrations},
+		{"scheduler", s.checkScheduler},
+	}
+	for _, c := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		started := time.Now()
+		detail, err := c.check(checkCtx)
+		latency := time.Since(started)
+		cancel()
+
+		result := model.HealthCheck{Status: model.HealthStatusOK, Detail: detail}
+		if err != nil {
+			result.Status, result.Detail = model.HealthStatusFail, err.Error()
+			report.Status = model.HealthStatusFail
+		}
+		if verbose {
+			result.Latency = latency.String()
+		}
+		report.Checks[c.name] = result
+	}
+	return report
+}
+
+func (s *HealthService) checkShutdown(ctx context.Context) (string, error) {
+	if s.shuttingDown.Load() {
+		return "", errors.New("shutting down")
+	}
+	return "", nil
+}
+
+func (s *HealthService) checkDatabase(ctx context.Context) (string, error) {
+	if err := s.healthRepo.Ping(ctx); err != nil {
+		return "", fmt.Errorf("ping failed: %w", err)
+	}
+	return "", nil
+}
+
+func (s *HealthService) checkMigrations(ctx context.Context) (string, error) {
+	version, dirty, err := s.healthRepo.MigrationVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	return compareMigrationVersion(version, dirty, s.migrationVersion)
+}
+
+// compareMigrationVersion fails if the schema is dirty or older than expected. A newer schema
+// passes: during a rolling deploy the new version migrates first while old replicas keep serving.
+func compareMigrationVersion(version uint, dirty bool, expected uint) (string, error) {
+	if dirty {
+		return "", fmt.Errorf("migration %d failed and left the schema dirty", version)
+	}
+	if version < expected {
+		return "", fmt.Errorf("schema version %d is behind expected version %d", version, expected)
+	}
+	if version > expected {
+		return fmt.Sprintf("schema version %d is ahead of expected version %d", version, expected), nil
+	}
+	return fmt.Sprintf("schema version %d", version), nil
+}
+
+func (s *HealthService) checkScheduler(ctx context.Context) (string, error) {
+	lastPoll, pollInterval, lastErr := s.scheduleService.Health()
+	if pollInterval == 0 {
+		return "", errors.New("not started")
+	}
+	if lastPoll.IsZero() {
+		return "first poll in progress", nil
+	}
+
+	since := time.Since(lastPoll).Round(time.Millisecond)
+	if since > schedulerStaleAfter*pollInterval {
+		return "", fmt.Errorf("last poll %s ago, expected every %s", since, pollInterval)
+	}
+	if lastErr != nil {
+		return fmt.Sprintf("last poll %s ago failed: %v", since, lastErr), nil
+	}
+	return fmt.Sprintf("last poll %s ago", since), nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+func TestCompareMigrationVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		version    uint
+		dirty      bool
+		wantErr    bool
+		wantDetail string
+	}{
+		{"current", 21, false, false, "schema version 21"},
+		{"ahead during a rolling deploy", 22, false, false, "schema version 22 is ahead of expected version 21"},
+		{"behind", 20, false, true, ""},
+		{"dirty", 21, true, true, ""},
+		{"dirty and ahead", 22, true, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detail, err := compareMigrationVersion(tt.version, tt.dirty, 21)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compareMigrationVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if detail != tt.wantDetail {
+				t.Errorf("compareMigrationVersion() detail = %q, want %q", detail, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestCheckScheduler(t *testing.T) {
+	tests := []struct {
+		name         string
+		pollInterval time.Duration
+		sinceLast    time.Duration // zero means no poll has finished yet
+		lastErr      error
+		wantErr      bool
+		wantDetail   string
+	}{
+		{"not started", 0, 0, nil, true, ""},
+		{"first poll in progress", time.Second, 0, nil, false, "first poll in progress"},
+		{"recent poll", time.Second, 500 * time.Millisecond, nil, false, "last poll"},
+		{"missed a poll", time.Second, 2 * time.Second, nil, false, "last poll"},
+		{"stuck", time.Second, schedulerStaleAfter*time.Second + time.Second, nil, true, ""},
+		{"last poll failed", time.Second, 500 * time.Millisecond, errors.New("connection reset"), false, "failed: connection reset"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheduler := &ScheduleService{pollInterval: tt.pollInterval, lastPollErr: tt.lastErr}
+			if tt.sinceLast > 0 {
+				scheduler.lastPoll = time.Now().Add(-tt.sinceLast)
+			}
+			s := &HealthService{scheduleService: scheduler}
+
+			detail, err := s.checkScheduler(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkScheduler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.Contains(detail, tt.wantDetail) {
+				t.Errorf("checkScheduler() detail = %q, want it to contain %q", detail, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestCheckShutdown(t *testing.T) {
+	s := &HealthService{}
+	if _, err := s.checkShutdown(context.Background()); err != nil {
+		t.Fatalf("checkShutdown() before ShutDown error = %v", err)
+	}
+	s.ShutDown()
+	if _, err := s.checkShutdown(context.Background()); err == nil {
+		t.Error("checkShutdown() after ShutDown error = nil, want an error")
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	db := openTestDB(t)
+	scheduler := &ScheduleService{pollInterval: time.Second, lastPoll: time.Now()}
+	var version uint
+	if err := db.QueryRow("SELECT version FROM schema_migrations").Scan(&version); err != nil {
+		t.Fatalf("failed to get migration version: %v", err)
+	}
+
+	s := NewHealthService(repository.NewHealthRepository(db), scheduler, version, time.Second)
+	report := s.Readiness(context.Background(), true)
+	if report.Status != model.HealthStatusOK {
+		t.Fatalf("Readiness() = %+v, want ok", report)
+	}
+	for _, name := range []string{"shutdown", "database", "migrations", "scheduler"} {
+		check, ok := report.Checks[name]
+		if !ok || check.Status != model.HealthStatusOK || check.Latency == "" {
+			t.Errorf("check %s = %+v, want ok with a latency", name, check)
+		}
+	}
+
+	s = NewHealthService(repository.NewHealthRepository(db), scheduler, version+1, time.Second)
+	if report := s.Readiness(context.Background(), false); report.Status != model.HealthStatusFail ||
+		report.Checks["migrations"].Status != model.HealthStatusFail || report.Checks["database"].Status != model.HealthStatusOK {
+		t.Errorf("Readiness() with a newer binary = %+v, want only migrations to fail", report)
+	}
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/metrics"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+var (
+	ErrHoldNotFound  = errors.New("hold not found")
+	ErrHoldNotActive = errors.New("hold is not active")
+	ErrHoldExpired   = errors.New("hold expired")
+)
+
+type HoldService struct {
+	holdRepo  *repository.HoldRepository
+	merchRepo *repository.MerchRepository
+	db        *sql.DB
+	// ttl is how long a hold reserves coins before it expires.
+	ttl    time.Duration
+	logger *slog.Logger
+}
+
+func NewHoldService(holdRepo *repository.HoldRepository, merchRepo *repository.MerchRepository, db *sql.DB, ttl time.Duration, logger *slog.Logger) *HoldService {
+	return &HoldService{
+		holdRepo:  holdRepo,
+		merchRepo: merchRepo,
+		db:        db,
+		ttl:       ttl,
+		logger:    logger,
+	}
+}
+
+// Create reserves the price of the item from the user's available coins. The item
+// does not have to be in stock, which is what makes a hold a pre-order.
+func (s *HoldService) Create(ctx context.Context, userID int, itemName string) (*model.Hold, error) {
+	merchItem, err := s.merchRepo.GetMerchItemByName(ctx, itemName)
+	if errors.Is(err, repository.ErrMerchItemNotFound) {
+		return nil, ErrMerchNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get merch item: %w", err)
+	}
+	// Holds reserve stock of the item itself, which variant items do not have.
+	if _, err := resolveVariant(ctx, s.merchRepo, merchItem, ""); err != nil {
+		return nil, err
+	}
+
+	var hold *model.Hold
+	err = runWithRetry(ctx, func() error {
+		var err error
+		hold, err = s.create(ctx, userID, merchItem)
+		return err
+	})
+	if errors.Is(err, ErrInsufficientFunds) {
+		metrics.InsufficientFunds(metrics.OperationHold)
+	}
+	return hold, err
+}
+
+func (s *HoldService) create(ctx context.Context, userID int, merchItem model.Merch) (*model.Hold, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil || err != nil {
+			rollback(ctx, s.logger, tx, "HoldService.create", err, p)
+		}
+	}()
+
+	userRepoTx := repository.NewUserRepositoryWithTx(tx)
+	if _, err = userRepoTx.GetByIDForUpdate(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	available, err := availableCoins(ctx, userRepoTx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if available < merchItem.Price {
+		err = ErrInsufficientFunds
+		return nil, err
+	}
+
+	hold, err := repository.NewHoldRepositoryWithTx(tx).Create(ctx, model.Hold{
+		UserID:    userID,
+		ItemID:    merchItem.ID,
+		Amount:    merchItem.Price,
+		ExpiresAt: time.Now().Add(s.ttl),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &hold, nil
+}
+
+func (s *HoldService) List(ctx context.Context, userID int) ([]model.Hold, error) {
+	if err := s.holdRepo.ExpireDue(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	holds, err := s.holdRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list holds: %w", err)
+	}
+	return holds, nil
+}
+
+// Capture turns the hold into a purchase of its item at the held price.
+// If userID is not 0, only the owner of the hold may capture it.
+func (s *HoldService) Capture(ctx context.Context, userID int, id int) (*model.Hold, error) {
+	var hold *model.Hold
+	err := runWithRetry(ctx, func() error {
+		var err error
+		hold, err = s.resolve(ctx, userID, id, model.HoldStatusCaptured)
+		return err
+	})
+	if errors.Is(err, ErrInsufficientFunds) {
+		metrics.InsufficientFunds(metrics.OperationPurchase)
+	}
+	return hold, err
+}
+
+// Release gives the held coins back to the available balance.
+// If userID is not 0, only the owner of the hold may release it.
+func (s *HoldService) Release(ctx context.Context, userID int, id int) (*model.Hold, error) {
+	return s.resolve(ctx, userID, id, model.HoldStatusReleased)
+}
+
+func (s *HoldService) resolve(ctx context.Context, userID int, id int, status string) (*model.Hold, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil || err != nil {
+			rollback(ctx, s.logger, tx, "HoldService.resolve", err, p)
+		}
+	}()
+
+	holdRepoTx := repository.NewHoldRepositoryWithTx(tx)
+
+	hold, err := holdRepoTx.GetForUpdate(ctx, id)
+	if errors.Is(err, repository.ErrHoldNotFound) {
+		err = ErrHoldNotFound
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get hold: %w", err)
+	}
+	if userID != 0 && hold.UserID != userID {
+		err = ErrHoldNotFound
+		return nil, err
+	}
+
+	if hold.Status == model.HoldStatusActive && !time.Now().Before(hold.ExpiresAt) {
+		if err = holdRepoTx.Resolve(ctx, hold.ID, model.HoldStatusExpired); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil, ErrHoldExpired
+	}
+	if hold.Status != model.HoldStatusActive {
+		err = ErrHoldNotActive
+		return nil, err
+	}
+
+	// The user row is locked before the hold stops counting, so the debit below sees it released.
+	userRepoTx := repository.NewUserRepositoryWithTx(tx)
+	if _, err = userRepoTx.GetByIDForUpdate(ctx, hold.UserID); err != nil {
+		return nil, fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	if err = holdRepoTx.Resolve(ctx, hold.ID, status); err != nil {
+		return nil, err
+	}
+	hold.Status = status
+
+	if status == model.HoldStatusCaptured {
+		if hold.PurchaseID, err = capturePurchase(ctx, tx, hold); err != nil {
+			return nil, err
+		}
+		if err = holdRepoTx.LinkPurchase(ctx, hold.ID, hold.PurchaseID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if status == model.HoldStatusCaptured {
+		metrics.PurchaseCompleted(hold.ItemName, hold.Amount)
+	}
+	return &hold, nil
+}
+
+// capturePurchase takes the item from stock and books the purchase at the held amount.
+func capturePurchase(ctx context.Context, tx *sql.Tx, hold model.Hold) (int, error) {
+	merchRepoTx := repository.NewMerchRepositoryWithTx(tx)
+	if err := merchRepoTx.DecrementStock(ctx, hold.ItemID, 1); errors.Is(err, repository.ErrMerchOutOfStock) {
+		return 0, ErrOutOfStock
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to decrement stock: %w", err)
+	}
+
+	entryID, err := postPurchase(ctx, tx, hold.UserID, hold.Amount,
+		fmt.Sprintf("purchase of %s by user %d from hold %d", hold.ItemName, hold.UserID, hold.ID))
+	if errors.Is(err, repository.ErrNegativeBalance) {
+		return 0, ErrInsufficientFunds
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to post purchase: %w", err)
+	}
+
+	purchaseID, err := repository.NewTransactionRepositoryWithTx(tx).CreatePurchase(ctx, model.Purchase{
+		UserID:   hold.UserID,
+		ItemID:   hold.ItemID,
+		ItemName: hold.ItemName,
+		Price:    hold.Amount,
+	}, entryID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record purchase: %w", err)
+	}
+	return purchaseID, nil
+}
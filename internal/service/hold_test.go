@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+func newTestHoldService(db *sql.DB) *HoldService {
+	return NewHoldService(repository.NewHoldRepository(db), repository.NewMerchRepository(db), db, time.Hour, discardLogger())
+}
+
+func createTestHold(t *testing.T, svc *HoldService, userID int, itemName string) *model.Hold {
+	t.Helper()
+	hold, err := svc.Create(context.Background(), userID, itemName)
+	if err != nil {
+		t.Fatalf("Create(%s) hold error = %v", itemName, err)
+	}
+	return hold
+}
+
+func TestHoldCapture(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestHoldService(db)
+	buyer, other := createTestUser(t, db, 1), createTestUser(t, db, 2)
+	setStock(t, db, "powerbank", 3)
+
+	hold := createTestHold(t, svc, buyer, "powerbank")
+	if got := userCoins(t, db, buyer); got != 1000 {
+		t.Errorf("buyer has %d coins with a hold, want 1000", got)
+	}
+
+	if _, err := svc.Capture(ctx, other, hold.ID); !errors.Is(err, ErrHoldNotFound) {
+		t.Errorf("Capture() by another user error = %v, want %v", err, ErrHoldNotFound)
+	}
+	captured, err := svc.Capture(ctx, buyer, hold.ID)
+	if err != nil {
+		t.Fatalf("Capture() error = %v", err)
+	}
+	if captured.Status != model.HoldStatusCaptured || captured.PurchaseID == 0 {
+		t.Errorf("Capture() = %+v, want captured with a purchase", captured)
+	}
+	if got := userCoins(t, db, buyer); got != 800 {
+		t.Errorf("buyer has %d coins after capture, want 800", got)
+	}
+	if got := itemStock(t, db, "powerbank"); got != 2 {
+		t.Errorf("stock after capture = %d, want 2", got)
+	}
+
+	if _, err := svc.Capture(ctx, buyer, hold.ID); !errors.Is(err, ErrHoldNotActive) {
+		t.Errorf("second Capture() error = %v, want %v", err, ErrHoldNotActive)
+	}
+	if _, err := svc.Release(ctx, buyer, hold.ID); !errors.Is(err, ErrHoldNotActive) {
+		t.Errorf("Release() of a captured hold error = %v, want %v", err, ErrHoldNotActive)
+	}
+	if got := userCoins(t, db, buyer); got != 800 {
+		t.Errorf("buyer has %d coins after capturing twice, want 800", got)
+	}
+	checkBooks(t, db)
+}
+
+func TestHoldReleaseAndExpiryFreeCoins(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestHoldService(db)
+	wallet := NewWalletService(repository.NewUserRepository(db), repository.NewTransactionRepository(db), db, time.Hour, discardLogger())
+	buyer, receiver := createTestUser(t, db, 1), createTestUser(t, db, 2)
+
+	released := createTestHold(t, svc, buyer, "pink-hoody")
+	expired := createTestHold(t, svc, buyer, "pink-hoody")
+	if err := wallet.Transfer(ctx, model.Transaction{SenderID: buyer, ReceiverID: receiver, Amount: 1}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("Transfer() of held coins error = %v, want %v", err, ErrInsufficientFunds)
+	}
+
+	if hold, err := svc.Release(ctx, buyer, released.ID); err != nil || hold.Status != model.HoldStatusReleased {
+		t.Fatalf("Release() = %+v, %v, want released", hold, err)
+	}
+	if _, err := db.Exec("UPDATE holds SET expires_at = CURRENT_TIMESTAMP - INTERVAL '1 minute' WHERE id = $1", expired.ID); err != nil {
+		t.Fatalf("failed to expire hold: %v", err)
+	}
+
+	if _, err := svc.Capture(ctx, buyer, expired.ID); !errors.Is(err, ErrHoldExpired) {
+		t.Errorf("Capture() of an expired hold error = %v, want %v", err, ErrHoldExpired)
+	}
+	holds, err := svc.List(ctx, buyer)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	for _, hold := range holds {
+		if hold.ID == expired.ID && hold.Status != model.HoldStatusExpired {
+			t.Errorf("expired hold has status %q, want %q", hold.Status, model.HoldStatusExpired)
+		}
+	}
+
+	if err := wallet.Transfer(ctx, model.Transaction{SenderID: buyer, ReceiverID: receiver, Amount: 1000}); err != nil {
+		t.Errorf("Transfer() of released coins error = %v", err)
+	}
+	checkBooks(t, db)
+}
+
+// TestHeldCoinsCannotBeSpent holds most of a balance and checks that every way of spending
+// coins is limited to the part that is not held.
+func TestHeldCoinsCannotBeSpent(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	holds := newTestHoldService(db)
+	wallet := NewWalletService(repository.NewUserRepository(db), repository.NewTransactionRepository(db), db, time.Hour, discardLogger())
+	merch := newTestMerchService(db)
+	carts := newTestCartService(db)
+	buyer, other := createTestUser(t, db, 1), createTestUser(t, db, 2)
+
+	// 1000 coins with 900 held leaves 100 available.
+	createTestHold(t, holds, buyer, "pink-hoody")
+	createTestHold(t, holds, buyer, "powerbank")
+	createTestHold(t, holds, buyer, "powerbank")
+	if _, err := holds.Create(ctx, buyer, "powerbank"); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Create() hold over the available coins error = %v, want %v", err, ErrInsufficientFunds)
+	}
+
+	if err := wallet.Transfer(ctx, model.Transaction{SenderID: buyer, ReceiverID: other, Amount: 101}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Transfer() error = %v, want %v", err, ErrInsufficientFunds)
+	}
+	if _, _, err := wallet.TransferBatch(ctx, nil, buyer, []model.Transaction{{ReceiverID: other, Amount: 51}, {ReceiverID: other, Amount: 50}}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("TransferBatch() error = %v, want %v", err, ErrInsufficientFunds)
+	}
+	if err := merch.PurchaseMerch(ctx, buyer, "hoody", "", ""); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("PurchaseMerch() error = %v, want %v", err, ErrInsufficientFunds)
+	}
+	if _, err := carts.AddItem(ctx, buyer, "book", 3); err != nil {
+		t.Fatalf("AddItem() error = %v", err)
+	}
+	if _, err := carts.Checkout(ctx, buyer, ""); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Checkout() error = %v, want %v", err, ErrInsufficientFunds)
+	}
+	if got := userCoins(t, db, buyer); got != 1000 {
+		t.Errorf("buyer has %d coins, want 1000", got)
+	}
+
+	// The receiver of a transfer may hold the coins before it is reversed.
+	if err := wallet.Transfer(ctx, model.Transaction{SenderID: other, ReceiverID: buyer, Amount: 200}); err != nil {
+		t.Fatalf("Transfer() error = %v", err)
+	}
+	id := lastTransferID(t, db, other)
+	createTestHold(t, holds, buyer, "powerbank")
+	if _, err := wallet.CancelTransfer(ctx, other, id); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("CancelTransfer() of held coins error = %v, want %v", err, ErrInsufficientFunds)
+	}
+
+	if err := wallet.Transfer(ctx, model.Transaction{SenderID: buyer, ReceiverID: other, Amount: 100}); err != nil {
+		t.Errorf("Transfer() of the available coins error = %v", err)
+	}
+	checkBooks(t, db)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+var (
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+)
+
+// claimIdempotencyKey reserves idem.Key inside tx. If the key has already been used,
+// the stored record is returned so the caller can replay it instead of repeating the operation.
+func claimIdempotencyKey(ctx context.Context, tx *sql.Tx, idem *model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	idempotencyRepoTx := repository.NewIdempotencyRepositoryWithTx(tx)
+
+	reserved, err := idempotencyRepoTx.Reserve(ctx, idem.UserID, idem.Key, idem.RequestHash)
+	if err != nil {
+		return nil, err
+	}
+	if reserved {
+		return nil, nil
+	}
+
+	stored, err := idempotencyRepoTx.Get(ctx, idem.UserID, idem.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load idempotency key: %w", err)
+	}
+	if stored.RequestHash != idem.RequestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	stored.Replayed = true
+	return stored, nil
+}
+
+func storeIdempotentResponse(ctx context.Context, tx *sql.Tx, idem *model.IdempotencyRecord) error {
+	idempotencyRepoTx := repository.NewIdempotencyRepositoryWithTx(tx)
+	return idempotencyRepoTx.SaveResponse(ctx, idem.UserID, idem.Key, idem.ResponseStatus, idem.ResponseBody)
+}
+
+// IdempotencyService deletes idempotency keys once they are too old to be retried with.
+type IdempotencyService struct {
+	idempotencyRepo *repository.IdempotencyRepository
+	// ttl is how long a key is kept; a request retried with an older key runs again.
+	ttl    time.Duration
+	logger *slog.Logger
+}
+
+func NewIdempotencyService(idempotencyRepo *repository.IdempotencyRepository, ttl time.Duration, logger *slog.Logger) *IdempotencyService {
+	return &IdempotencyService{
+		idempotencyRepo: idempotencyRepo,
+		ttl:             ttl,
+		logger:          logger,
+	}
+}
+
+// PurgeExpired deletes the keys older than the TTL and returns how many were deleted.
+func (s *IdempotencyService) PurgeExpired(ctx context.Context) (int64, error) {
+	deleted, err := s.idempotencyRepo.DeleteCreatedBefore(ctx, time.Now().Add(-s.ttl))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge idempotency keys: %w", err)
+	}
+	return deleted, nil
+}
+
+// Run purges expired keys every interval until ctx is cancelled.
+func (s *IdempotencyService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := s.PurgeExpired(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.logger.ErrorContext(ctx, "failed to purge expired idempotency keys", "error", err)
+		} else if deleted > 0 {
+			s.logger.InfoContext(ctx, "purged expired idempotency keys", "deleted", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
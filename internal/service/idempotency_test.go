@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+func TestTransferIdempotentReplaysStoredResponse(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := NewWalletService(repository.NewUserRepository(db), repository.NewTransactionRepository(db), db, time.Hour, discardLogger())
+	sender, receiver := createTestUser(t, db, 1), createTestUser(t, db, 2)
+	transfer := model.Transaction{SenderID: sender, ReceiverID: receiver, Amount: 100}
+	idem := func(requestHash string) *model.IdempotencyRecord {
+		return &model.IdempotencyRecord{UserID: sender, Key: "key-1", RequestHash: requestHash, ResponseStatus: 200, ResponseBody: []byte(`{"ok":true}`)}
+	}
+
+	first, err := svc.TransferIdempotent(ctx, idem("hash-1"), transfer)
+	if err != nil {
+		t.Fatalf("TransferIdempotent() error = %v", err)
+	}
+	if first.Replayed {
+		t.Errorf("first TransferIdempotent() was replayed")
+	}
+
+	replayed, err := svc.TransferIdempotent(ctx, idem("hash-1"), transfer)
+	if err != nil {
+		t.Fatalf("repeated TransferIdempotent() error = %v", err)
+	}
+	if !replayed.Replayed || replayed.ResponseStatus != 200 || !bytes.Equal(replayed.ResponseBody, first.ResponseBody) {
+		t.Errorf("repeated TransferIdempotent() = %+v, want the stored response replayed", replayed)
+	}
+	if got := userCoins(t, db, sender); got != 900 {
+		t.Errorf("sender has %d coins after a replayed transfer, want 900", got)
+	}
+	if got := userCoins(t, db, receiver); got != 1100 {
+		t.Errorf("receiver has %d coins after a replayed transfer, want 1100", got)
+	}
+
+	if _, err := svc.TransferIdempotent(ctx, idem("hash-2"), transfer); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("TransferIdempotent() with a different request error = %v, want %v", err, ErrIdempotencyKeyReused)
+	}
+	// Keys belong to the user, so another user's key of the same name is unrelated.
+	other := &model.IdempotencyRecord{UserID: receiver, Key: "key-1", RequestHash: "hash-3", ResponseStatus: 200}
+	if _, err := svc.TransferIdempotent(ctx, other, model.Transaction{SenderID: receiver, ReceiverID: sender, Amount: 50}); err != nil {
+		t.Errorf("TransferIdempotent() with another user's key error = %v", err)
+	}
+	if got := userCoins(t, db, sender); got != 950 {
+		t.Errorf("sender has %d coins, want 950", got)
+	}
+	checkBooks(t, db)
+}
+
+func TestPurchaseIdempotentRejectsKeyOfAnotherRequest(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMerchService(db)
+	buyer := createTestUser(t, db, 1)
+	setStock(t, db, "cup", 3)
+
+	if _, err := svc.PurchaseMerchIdempotent(ctx, &model.IdempotencyRecord{UserID: buyer, Key: "key-1", RequestHash: "cup"}, buyer, "cup", "", ""); err != nil {
+		t.Fatalf("PurchaseMerchIdempotent() error = %v", err)
+	}
+	replayed, err := svc.PurchaseMerchIdempotent(ctx, &model.IdempotencyRecord{UserID: buyer, Key: "key-1", RequestHash: "cup"}, buyer, "cup", "", "")
+	if err != nil || !replayed.Replayed {
+		t.Fatalf("repeated PurchaseMerchIdempotent() = %+v, %v, want a replay", replayed, err)
+	}
+	if _, err := svc.PurchaseMerchIdempotent(ctx, &model.IdempotencyRecord{UserID: buyer, Key: "key-1", RequestHash: "pen"}, buyer, "pen", "", ""); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("PurchaseMerchIdempotent() with a different request error = %v, want %v", err, ErrIdempotencyKeyReused)
+	}
+
+	if got := itemStock(t, db, "cup"); got != 2 {
+		t.Errorf("stock after a replayed purchase = %d, want 2", got)
+	}
+	if got := userCoins(t, db, buyer); got != 980 {
+		t.Errorf("buyer has %d coins after a replayed purchase, want 980", got)
+	}
+	checkBooks(t, db)
+}
+
+func TestPurgeExpiredIdempotencyKeys(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	wallet := NewWalletService(repository.NewUserRepository(db), repository.NewTransactionRepository(db), db, time.Hour, discardLogger())
+	sender, receiver := createTestUser(t, db, 1), createTestUser(t, db, 2)
+	transfer := model.Transaction{SenderID: sender, ReceiverID: receiver, Amount: 100}
+	idem := func() *model.IdempotencyRecord {
+		return &model.IdempotencyRecord{UserID: sender, Key: "key-1", RequestHash: "hash-1", ResponseStatus: 200}
+	}
+
+	if _, err := wallet.TransferIdempotent(ctx, idem(), transfer); err != nil {
+		t.Fatalf("TransferIdempotent() error = %v", err)
+	}
+	kept, err := NewIdempotencyService(repository.NewIdempotencyRepository(db), time.Hour, discardLogger()).PurgeExpired(ctx)
+	if err != nil || kept != 0 {
+		t.Fatalf("PurgeExpired() within the TTL = %d, %v, want 0", kept, err)
+	}
+	deleted, err := NewIdempotencyService(repository.NewIdempotencyRepository(db), 0, discardLogger()).PurgeExpired(ctx)
+	if err != nil || deleted != 1 {
+		t.Fatalf("PurgeExpired() past the TTL = %d, %v, want 1", deleted, err)
+	}
+
+	// Once purged, the key runs the request again.
+	record, err := wallet.TransferIdempotent(ctx, idem(), transfer)
+	if err != nil || record.Replayed {
+		t.Fatalf("TransferIdempotent() with a purged key = %+v, %v, want a new transfer", record, err)
+	}
+	if got := userCoins(t, db, sender); got != 800 {
+		t.Errorf("sender has %d coins, want 800", got)
+	}
+}
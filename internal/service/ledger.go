@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+type LedgerService struct {
+	ledgerRepo *repository.LedgerRepository
+}
+
+func NewLedgerService(ledgerRepo *repository.LedgerRepository) *LedgerService {
+	return &LedgerService{ledgerRepo: ledgerRepo}
+}
+
+func (s *LedgerService) CheckBooks(ctx context.Context) (*model.LedgerReport, error) {
+	report, err := s.ledgerRepo.Report(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build ledger report: %w", err)
+	}
+	return report, nil
+}
+
+func postTransfer(ctx context.Context, tx *sql.Tx, senderID, receiverID int, amount int) (int, error) {
+	return repository.NewLedgerRepositoryWithTx(tx).Post(ctx, transferEntry(senderID, receiverID, amount))
+}
+
+func transferEntry(senderID, receiverID int, amount int) model.JournalEntry {
+	return model.JournalEntry{
+		Kind:        model.EntryKindTransfer,
+		Description: fmt.Sprintf("transfer from user %d to user %d", senderID, receiverID),
+		Postings: []model.Posting{
+			{UserID: senderID, Amount: -amount},
+			{UserID: receiverID, Amount: amount},
+		},
+	}
+}
+
+// postBatchTransfer books a whole batch as one entry: the sender is debited the total once.
+func postBatchTransfer(ctx context.Context, tx *sql.Tx, senderID int, transfers []model.Transaction) (int, error) {
+	return repository.NewLedgerRepositoryWithTx(tx).Post(ctx, batchTransferEntry(senderID, transfers))
+}
+
+func batchTransferEntry(senderID int, transfers []model.Transaction) model.JournalEntry {
+	total := 0
+	postings := make([]model.Posting, 0, len(transfers)+1)
+	for _, t := range transfers {
+		total += t.Amount
+		postings = append(postings, model.Posting{UserID: t.ReceiverID, Amount: t.Amount})
+	}
+	postings = append(postings, model.Posting{UserID: senderID, Amount: -total})
+
+	return model.JournalEntry{
+		Kind:        model.EntryKindTransfer,
+		Description: fmt.Sprintf("batch transfer from user %d to %d recipients", senderID, len(transfers)),
+		Postings:    postings,
+	}
+}
+
+func postPurchase(ctx context.Context, tx *sql.Tx, userID int, amount int, description string) (int, error) {
+	return repository.NewLedgerRepositoryWithTx(tx).Post(ctx, purchaseEntry(userID, amount, description))
+}
+
+func purchaseEntry(userID int, amount int, description string) model.JournalEntry {
+	return model.JournalEntry{
+		Kind:        model.EntryKindPurchase,
+		Description: description,
+		Postings: []model.Posting{
+			{UserID: userID, Amount: -amount},
+			{AccountCode: model.AccountMerchRevenue, Amount: amount},
+		},
+	}
+}
+
+// postReversal books the compensating entry of a transfer: the coins go back from its receiver to its sender.
+func postReversal(ctx context.Context, tx *sql.Tx, original model.Transaction) (int, error) {
+	return repository.NewLedgerRepositoryWithTx(tx).Post(ctx, reversalEntry(original))
+}
+
+func reversalEntry(original model.Transaction) model.JournalEntry {
+	return model.JournalEntry{
+		Kind:        model.EntryKindRefund,
+		Description: fmt.Sprintf("reversal of transaction %d", original.ID),
+		Postings: []model.Posting{
+			{UserID: original.ReceiverID, Amount: -original.Amount},
+			{UserID: original.SenderID, Amount: original.Amount},
+		},
+	}
+}
+
+// postRefund returns the price of a purchase from merch revenue to the buyer.
+func postRefund(ctx context.Context, tx *sql.Tx, userID int, amount int, description string) (int, error) {
+	return repository.NewLedgerRepositoryWithTx(tx).Post(ctx, refundEntry(userID, amount, description))
+}
+
+func refundEntry(userID int, amount int, description string) model.JournalEntry {
+	return model.JournalEntry{
+		Kind:        model.EntryKindRefund,
+		Description: description,
+		Postings: []model.Posting{
+			{AccountCode: model.AccountMerchRevenue, Amount: -amount},
+			{UserID: userID, Amount: amount},
+		},
+	}
+}
+
+// postAdjustment books an admin correction; a positive delta credits the user.
+func postAdjustment(ctx context.Context, tx *sql.Tx, userID int, delta int) (int, error) {
+	return repository.NewLedgerRepositoryWithTx(tx).Post(ctx, adjustmentEntry(userID, delta))
+}
+
+func adjustmentEntry(userID int, delta int) model.JournalEntry {
+	return model.JournalEntry{
+		Kind:        model.EntryKindAdjustment,
+		Description: fmt.Sprintf("admin adjustment for user %d", userID),
+		Postings: []model.Posting{
+			{UserID: userID, Amount: delta},
+			{AccountCode: model.AccountAdminAdjustment, Amount: -delta},
+		},
+	}
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+// entryDeltas returns how much the entry changes the balance of each user and of each system account.
+func entryDeltas(entry model.JournalEntry) (map[int]int, map[string]int) {
+	users, accounts := map[int]int{}, map[string]int{}
+	for _, p := range entry.Postings {
+		if p.UserID != 0 {
+			users[p.UserID] += p.Amount
+		} else {
+			accounts[p.AccountCode] += p.Amount
+		}
+	}
+	return users, accounts
+}
+
+func TestLedgerEntriesBalance(t *testing.T) {
+	batch := []model.Transaction{
+		{ReceiverID: 2, Amount: 10},
+		{ReceiverID: 3, Amount: 25},
+		{ReceiverID: 2, Amount: 5},
+	}
+	tests := []struct {
+		name         string
+		entry        model.JournalEntry
+		kind         string
+		wantUsers    map[int]int
+		wantAccounts map[string]int
+	}{
+		{
+			name:      "transfer",
+			entry:     transferEntry(1, 2, 40),
+			kind:      model.EntryKindTransfer,
+			wantUsers: map[int]int{1: -40, 2: 40},
+		},
+		{
+			name:      "batch transfer debits the sender the total once",
+			entry:     batchTransferEntry(1, batch),
+			kind:      model.EntryKindTransfer,
+			wantUsers: map[int]int{1: -40, 2: 15, 3: 25},
+		},
+		{
+			name:         "purchase",
+			entry:        purchaseEntry(1, 80, "t-shirt"),
+			kind:         model.EntryKindPurchase,
+			wantUsers:    map[int]int{1: -80},
+			wantAccounts: map[string]int{model.AccountMerchRevenue: 80},
+		},
+		{
+			name:      "reversal returns the coins to the sender",
+			entry:     reversalEntry(model.Transaction{ID: 7, SenderID: 1, ReceiverID: 2, Amount: 40}),
+			kind:      model.EntryKindRefund,
+			wantUsers: map[int]int{1: 40, 2: -40},
+		},
+		{
+			name:         "refund",
+			entry:        refundEntry(1, 80, "refund of purchase 3"),
+			kind:         model.EntryKindRefund,
+			wantUsers:    map[int]int{1: 80},
+			wantAccounts: map[string]int{model.AccountMerchRevenue: -80},
+		},
+		{
+			name:         "positive adjustment",
+			entry:        adjustmentEntry(1, 100),
+			kind:         model.EntryKindAdjustment,
+			wantUsers:    map[int]int{1: 100},
+			wantAccounts: map[string]int{model.AccountAdminAdjustment: -100},
+		},
+		{
+			name:         "negative adjustment",
+			entry:        adjustmentEntry(1, -30),
+			kind:         model.EntryKindAdjustment,
+			wantUsers:    map[int]int{1: -30},
+			wantAccounts: map[string]int{model.AccountAdminAdjustment: 30},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.entry.Balanced() {
+				t.Fatalf("entry is not balanced: %+v", tt.entry.Postings)
+			}
+			if tt.entry.Kind != tt.kind {
+				t.Errorf("kind = %q, want %q", tt.entry.Kind, tt.kind)
+			}
+
+			users, accounts := entryDeltas(tt.entry)
+			if len(users) != len(tt.wantUsers) {
+				t.Errorf("user deltas = %v, want %v", users, tt.wantUsers)
+			}
+			for id, want := range tt.wantUsers {
+				if users[id] != want {
+					t.Errorf("delta of user %d = %d, want %d", id, users[id], want)
+				}
+			}
+			if len(accounts) != len(tt.wantAccounts) {
+				t.Errorf("account deltas = %v, want %v", accounts, tt.wantAccounts)
+			}
+			for code, want := range tt.wantAccounts {
+				if accounts[code] != want {
+					t.Errorf("delta of account %s = %d, want %d", code, accounts[code], want)
+				}
+			}
+		})
+	}
+}
+
+func TestLedgerPostRejectsUnbalancedEntriesAndOverdrafts(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	alice, bob := createTestUser(t, db, 1), createTestUser(t, db, 2)
+
+	tests := []struct {
+		name    string
+		entry   model.JournalEntry
+		wantErr error
+	}{
+		{"unbalanced", model.JournalEntry{Kind: model.EntryKindTransfer, Postings: []model.Posting{
+			{UserID: alice, Amount: -10}, {UserID: bob, Amount: 5},
+		}}, repository.ErrUnbalancedEntry},
+		{"overdraft", transferEntry(alice, bob, 1001), repository.ErrNegativeBalance},
+		{"unknown account", model.JournalEntry{Kind: model.EntryKindTransfer, Postings: []model.Posting{
+			{UserID: alice, Amount: -10}, {AccountCode: "missing", Amount: 10},
+		}}, repository.ErrLedgerAccountNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %v", err)
+			}
+			defer tx.Rollback()
+
+			if _, err := repository.NewLedgerRepositoryWithTx(tx).Post(ctx, tt.entry); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Post() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if coins := userCoins(t, db, alice); coins != 1000 {
+		t.Errorf("alice has %d coins, want 1000", coins)
+	}
+	checkBooks(t, db)
+}
+
+func TestLedgerMatchesBalancesAfterEveryKindOfEntry(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	logger := discardLogger()
+	userRepo := repository.NewUserRepository(db)
+	walletService := NewWalletService(userRepo, repository.NewTransactionRepository(db), db, time.Hour, logger)
+	merchService := NewMerchService(repository.NewMerchRepository(db), userRepo, db, logger)
+	alice, bob, carol := createTestUser(t, db, 1), createTestUser(t, db, 2), createTestUser(t, db, 3)
+
+	if err := walletService.Transfer(ctx, model.Transaction{SenderID: alice, ReceiverID: bob, Amount: 100}); err != nil {
+		t.Fatalf("Transfer() error = %v", err)
+	}
+	_, _, err := walletService.TransferBatch(ctx, nil, bob, []model.Transaction{
+		{ReceiverID: alice, Amount: 30},
+		{ReceiverID: carol, Amount: 20},
+	})
+	if err != nil {
+		t.Fatalf("TransferBatch() error = %v", err)
+	}
+	if err := merchService.PurchaseMerch(ctx, carol, "t-shirt", "", ""); err != nil {
+		t.Fatalf("PurchaseMerch() error = %v", err)
+	}
+	if _, err := walletService.AdjustBalance(ctx, carol, -15); err != nil {
+		t.Fatalf("AdjustBalance() error = %v", err)
+	}
+	purchases, _, err := merchService.ListPurchases(ctx, carol, model.PurchaseFilter{})
+	if err != nil || len(purchases) != 1 {
+		t.Fatalf("ListPurchases() = %v, %v, want one purchase", purchases, err)
+	}
+	if _, err := merchService.RefundPurchase(ctx, purchases[0].ID); err != nil {
+		t.Fatalf("RefundPurchase() error = %v", err)
+	}
+
+	want := map[int]int{alice: 930, bob: 1050, carol: 1005}
+	for id, coins := range want {
+		if got := userCoins(t, db, id); got != coins {
+			t.Errorf("user %d has %d coins, want %d", id, got, coins)
+		}
+	}
+	report := checkBooks(t, db)
+	for _, account := range report.SystemAccounts {
+		if account.Code == model.AccountMerchRevenue && account.Balance != 0 {
+			t.Errorf("merch revenue = %d after the only purchase was refunded, want 0", account.Balance)
+		}
+	}
+}
@@ -5,14 +5,27 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/metrics"
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/tracing"
 )
 
 var (
-	ErrMerchNotFound = errors.New("merch not found")
+	ErrMerchNotFound      = errors.New("merch not found")
+	ErrMerchAlreadyExists = errors.New("merch already exists")
+	ErrInvalidMerchItem   = errors.New("invalid merch item")
+	ErrOutOfStock         = errors.New("merch out of stock")
+	ErrInvalidQuantity    = errors.New("invalid quantity")
+	ErrPurchaseNotFound   = errors.New("purchase not found")
+	ErrAlreadyRefunded    = errors.New("purchase already refunded")
+
+	ErrInvalidOrderStatus     = errors.New("invalid order status")
+	ErrInvalidOrderTransition = errors.New("order cannot move to this status")
 )
 
 type MerchService struct {
@@ -20,18 +33,23 @@ type MerchService struct {
 	userRepo        *repository.UserRepository
 	transactionRepo *repository.TransactionRepository
 	db              *sql.DB
+	logger          *slog.Logger
 }
 
-func NewMerchService(merchRepo *repository.MerchRepository, userRepo *repository.UserRepository, db *sql.DB) *MerchService {
+func NewMerchService(merchRepo *repository.MerchRepository, userRepo *repository.UserRepository, db *sql.DB, logger *slog.Logger) *MerchService {
 	return &MerchService{
 		merchRepo:       merchRepo,
 		userRepo:        userRepo,
 		transactionRepo: repository.NewTransactionRepository(db),
 		db:              db,
+		logger:          logger,
 	}
 }
 
-func (s *MerchService) ListMerch(ctx context.Context) ([]model.Merch, error) {
+func (s *MerchService) ListMerch(ctx context.Context) (_ []model.Merch, err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.ListMerch")
+	defer func() { tracing.End(span, err) }()
+
 	merchItems, err := s.merchRepo.ListMerchItems(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list merch items: %w", err)
@@ -39,85 +57,454 @@ func (s *MerchService) ListMerch(ctx context.Context) ([]model.Merch, error) {
 	return merchItems, nil
 }
 
-func (s *MerchService) PurchaseMerch(ctx context.Context, userID int, itemName string) error {
+func (s *MerchService) ListAllMerch(ctx context.Context) (_ []model.Merch, err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.ListAllMerch")
+	defer func() { tracing.End(span, err) }()
+
+	merchItems, err := s.merchRepo.ListAllMerchItems(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list merch items: %w", err)
+	}
+	return merchItems, nil
+}
+
+func (s *MerchService) CreateMerchItem(ctx context.Context, item model.Merch) (_ model.Merch, err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.CreateMerchItem", tracing.AttrItemName.String(item.Name))
+	defer func() { tracing.End(span, err) }()
+
+	item.Name = strings.TrimSpace(item.Name)
+	item.Category = strings.TrimSpace(item.Category)
+	if item.Name == "" || item.Price <= 0 || (item.Stock != nil && *item.Stock < 0) {
+		return model.Merch{}, ErrInvalidMerchItem
+	}
+
+	created, err := s.merchRepo.CreateMerchItem(ctx, item)
+	if errors.Is(err, repository.ErrMerchItemExists) {
+		return model.Merch{}, ErrMerchAlreadyExists
+	} else if err != nil {
+		return model.Merch{}, fmt.Errorf("failed to create merch item: %w", err)
+	}
+	return created, nil
+}
+
+func (s *MerchService) UpdateMerchItem(ctx context.Context, id int, update model.MerchUpdate) (_ model.Merch, err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.UpdateMerchItem", tracing.AttrItemID.Int(id))
+	defer func() { tracing.End(span, err) }()
+
+	item, err := s.merchRepo.GetMerchItemByID(ctx, id)
+	if errors.Is(err, repository.ErrMerchItemNotFound) {
+		return model.Merch{}, ErrMerchNotFound
+	} else if err != nil {
+		return model.Merch{}, fmt.Errorf("failed to get merch item %d: %w", id, err)
+	}
+
+	if update.Name != nil {
+		item.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Price != nil {
+		item.Price = *update.Price
+	}
+	if update.Description != nil {
+		item.Description = *update.Description
+	}
+	if update.Category != nil {
+		item.Category = strings.TrimSpace(*update.Category)
+	}
+	if update.Active != nil {
+		item.Active = *update.Active
+	}
+	if item.Name == "" || item.Price <= 0 {
+		return model.Merch{}, ErrInvalidMerchItem
+	}
+
+	updated, err := s.merchRepo.UpdateMerchItem(ctx, item)
+	if errors.Is(err, repository.ErrMerchItemNotFound) {
+		return model.Merch{}, ErrMerchNotFound
+	} else if errors.Is(err, repository.ErrMerchItemExists) {
+		return model.Merch{}, ErrMerchAlreadyExists
+	} else if err != nil {
+		return model.Merch{}, fmt.Errorf("failed to update merch item %d: %w", id, err)
+	}
+	return updated, nil
+}
+
+// DeactivateMerchItem hides the item from the catalog. Past purchases keep referring to it by name.
+func (s *MerchService) DeactivateMerchItem(ctx context.Context, id int) (_ model.Merch, err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.DeactivateMerchItem", tracing.AttrItemID.Int(id))
+	defer func() { tracing.End(span, err) }()
+
+	active := false
+	return s.UpdateMerchItem(ctx, id, model.MerchUpdate{Active: &active})
+}
+
+func (s *MerchService) RestockMerchItem(ctx context.Context, id int, quantity int) (_ model.Merch, err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.RestockMerchItem", tracing.AttrItemID.Int(id))
+	defer func() { tracing.End(span, err) }()
+
+	if quantity <= 0 {
+		return model.Merch{}, ErrInvalidQuantity
+	}
+
+	item, err := s.merchRepo.Restock(ctx, id, quantity)
+	if errors.Is(err, repository.ErrMerchItemNotFound) {
+		return model.Merch{}, ErrMerchNotFound
+	} else if err != nil {
+		return model.Merch{}, fmt.Errorf("failed to restock merch item %d: %w", id, err)
+	}
+	return item, nil
+}
+
+// PurchaseMerch buys one unit of the item, or of its variant with the given SKU if sku is not empty.
+// promoCode is optional; automatic promotions apply either way.
+func (s *MerchService) PurchaseMerch(ctx context.Context, userID int, itemName, sku, promoCode string) (err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.PurchaseMerch",
+		tracing.AttrUserID.Int(userID), tracing.AttrItemName.String(itemName), tracing.AttrSKU.String(sku), tracing.AttrPromoCode.String(promoCode))
+	defer func() { tracing.End(span, err) }()
+
+	_, err = s.PurchaseMerchIdempotent(ctx, nil, userID, itemName, sku, promoCode)
+	return err
+}
+
+// PurchaseMerchIdempotent performs the purchase and stores idem in the same transaction.
+// If idem.Key was already used for the same request, the stored record is returned and nothing is bought.
+func (s *MerchService) PurchaseMerchIdempotent(ctx context.Context, idem *model.IdempotencyRecord, userID int, itemName, sku, promoCode string) (_ *model.IdempotencyRecord, err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.PurchaseMerchIdempotent",
+		tracing.AttrUserID.Int(userID), tracing.AttrItemName.String(itemName), tracing.AttrSKU.String(sku), tracing.AttrPromoCode.String(promoCode))
+	defer func() { tracing.End(span, err) }()
+
+	var record *model.IdempotencyRecord
+	err = runWithRetry(ctx, func() error {
+		var err error
+		record, err = s.purchaseMerch(ctx, idem, userID, itemName, sku, strings.TrimSpace(promoCode))
+		return err
+	})
+	if errors.Is(err, ErrInsufficientFunds) {
+		metrics.InsufficientFunds(metrics.OperationPurchase)
+	}
+	return record, err
+}
+
+func (s *MerchService) purchaseMerch(ctx context.Context, idem *model.IdempotencyRecord, userID int, itemName, sku, promoCode string) (*model.IdempotencyRecord, error) {
 	merchItem, err := s.merchRepo.GetMerchItemByName(ctx, itemName)
+	if errors.Is(err, repository.ErrMerchItemNotFound) {
+		return nil, ErrMerchNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get merch item: %w", err)
+	}
+
+	variant, err := resolveVariant(ctx, s.merchRepo, merchItem, sku)
 	if err != nil {
-		return ErrMerchNotFound
+		return nil, err
+	}
+	listPrice := merchItem.Price
+	if variant != nil {
+		listPrice = variant.Price
 	}
 
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer func() {
 		if p := recover(); p != nil || err != nil {
-			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("failed to rollback transaction: %v", rbErr)
-			}
+			rollback(ctx, s.logger, tx, "MerchService.purchaseMerch", err, p)
 		}
 	}()
 
+	if idem != nil {
+		var stored *model.IdempotencyRecord
+		stored, err = claimIdempotencyKey(ctx, tx, idem)
+		if err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			rollback(ctx, s.logger, tx, "MerchService.purchaseMerch", nil, nil)
+			return stored, nil
+		}
+	}
+
 	userRepoTx := repository.NewUserRepositoryWithTx(tx)
-	user, err := userRepoTx.GetByID(ctx, userID)
+	if _, err = userRepoTx.GetByIDForUpdate(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	redemptions, discount, err := applyPromotions(ctx, tx, userID, merchItem, listPrice, promoCode)
+	if err != nil {
+		return nil, err
+	}
+	price := listPrice - discount
+
+	available, err := availableCoins(ctx, userRepoTx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if available < price {
+		err = ErrInsufficientFunds
+		return nil, err
+	}
+
+	if err = takeStock(ctx, repository.NewMerchRepositoryWithTx(tx), merchItem, variant); err != nil {
+		return nil, err
+	}
+
+	// A purchase discounted down to nothing moves no coins and has no journal entry.
+	entryID := 0
+	if price > 0 {
+		entryID, err = postPurchase(ctx, tx, userID, price, fmt.Sprintf("purchase of %s by user %d", itemName, userID))
+		if errors.Is(err, repository.ErrNegativeBalance) {
+			err = ErrInsufficientFunds
+			return nil, err
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to post purchase: %w", err)
+		}
+	}
+
+	transactionRepoTx := repository.NewTransactionRepositoryWithTx(tx)
+	purchaseID, err := transactionRepoTx.CreatePurchase(ctx, model.Purchase{
+		UserID:    userID,
+		ItemID:    merchItem.ID,
+		ItemName:  itemName,
+		SKU:       sku,
+		ListPrice: listPrice,
+		Discount:  discount,
+		Price:     price,
+	}, entryID)
 	if err != nil {
-		return fmt.Errorf("failed to get user: %w", err)
+		return nil, fmt.Errorf("failed to record purchase: %w", err)
 	}
 
-	if user.Coins < merchItem.Price {
-		return ErrInsufficientFunds
+	promotionRepoTx := repository.NewPromotionRepositoryWithTx(tx)
+	for _, redemption := range redemptions {
+		if err = promotionRepoTx.Redeem(ctx, userID, purchaseID, redemption); err != nil {
+			return nil, err
+		}
 	}
 
-	newBalance := user.Coins - merchItem.Price
-	if err = userRepoTx.UpdateCoins(ctx, userID, newBalance); err != nil {
-		return fmt.Errorf("failed to update user coins: %w", err)
+	if idem != nil {
+		if err = storeIdempotentResponse(ctx, tx, idem); err != nil {
+			return nil, fmt.Errorf("failed to store idempotency key: %w", err)
+		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	metrics.PurchaseCompleted(itemName, price)
+	return idem, nil
+}
+
+// ListPurchases returns one page of purchases and the cursor of the next page,
+// which is empty on the last page.
+func (s *MerchService) ListPurchases(ctx context.Context, userID int, filter model.PurchaseFilter) (_ []model.Purchase, _ string, err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.ListPurchases", tracing.AttrUserID.Int(userID))
+	defer func() { tracing.End(span, err) }()
+
+	filter.Page = normalizePage(filter.Page)
+
+	purchases, next, err := s.transactionRepo.GetPurchasesByUserID(ctx, userID, filter)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get purchases for user %d: %w", userID, err)
+	}
+	return purchases, EncodeCursor(next), nil
+}
+
+// RefundPurchase returns the price of a purchase to the buyer with a compensating ledger entry
+// and frees the promotions it used. The purchase itself is kept and marked as refunded; an
+// order not yet handed over is cancelled and its item returned to stock.
+func (s *MerchService) RefundPurchase(ctx context.Context, purchaseID int) (_ *model.Purchase, err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.RefundPurchase", tracing.AttrPurchaseID.Int(purchaseID))
+	defer func() { tracing.End(span, err) }()
+
+	var purchase *model.Purchase
+	err = runWithRetry(ctx, func() error {
+		var err error
+		purchase, err = s.refundPurchase(ctx, purchaseID)
+		return err
+	})
+	return purchase, err
+}
+
+func (s *MerchService) refundPurchase(ctx context.Context, purchaseID int) (*model.Purchase, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil || err != nil {
+			rollback(ctx, s.logger, tx, "MerchService.refundPurchase", err, p)
+		}
+	}()
+
 	transactionRepoTx := repository.NewTransactionRepositoryWithTx(tx)
-	if err = transactionRepoTx.CreatePurchase(ctx, userID, itemName, merchItem.Price); err != nil {
-		return fmt.Errorf("failed to record purchase: %w", err)
+	purchase, err := transactionRepoTx.GetPurchaseForUpdate(ctx, purchaseID)
+	if errors.Is(err, repository.ErrPurchaseNotFound) {
+		err = ErrPurchaseNotFound
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get purchase: %w", err)
+	}
+	if purchase.RefundedAt != "" {
+		err = ErrAlreadyRefunded
+		return nil, err
+	}
+
+	if err = payBackPurchase(ctx, tx, &purchase); err != nil {
+		return nil, err
+	}
+
+	// A refunded order that has not been handed over yet must not be fulfilled any more,
+	// so the item goes back to stock like on any other cancellation.
+	if model.CanTransitionOrder(purchase.Status, model.OrderStatusCancelled) {
+		if err = returnToStock(ctx, tx, purchase); err != nil {
+			return nil, err
+		}
+		if purchase, err = transactionRepoTx.SetPurchaseStatus(ctx, purchase.ID, model.OrderStatusCancelled); err != nil {
+			return nil, fmt.Errorf("failed to cancel order: %w", err)
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return nil
+	s.logger.InfoContext(ctx, "purchase refunded", "purchase_id", purchase.ID, "buyer_id", purchase.UserID)
+	return &purchase, nil
 }
 
-func (s *MerchService) ListPurchases(ctx context.Context, userID int) ([]model.Purchase, error) {
-	purchases, err := s.transactionRepo.GetPurchasesByUserID(ctx, userID)
+// payBackPurchase credits the price back to the buyer, marks the locked purchase refunded and
+// frees its promotion uses. A purchase made before the ledger existed has no journal entry but
+// was paid all the same, so its refund is booked against merch revenue like any other; one
+// discounted down to nothing moves no coins and is marked refunded without an entry.
+func payBackPurchase(ctx context.Context, tx *sql.Tx, purchase *model.Purchase) error {
+	entryID := 0
+	if purchase.Price > 0 {
+		userRepoTx := repository.NewUserRepositoryWithTx(tx)
+		if _, err := userRepoTx.GetByIDForUpdate(ctx, purchase.UserID); err != nil {
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+
+		var err error
+		entryID, err = postRefund(ctx, tx, purchase.UserID, purchase.Price,
+			fmt.Sprintf("refund of purchase %d (%s) to user %d", purchase.ID, purchase.ItemName, purchase.UserID))
+		if err != nil {
+			return fmt.Errorf("failed to post refund: %w", err)
+		}
+	}
+
+	refundedAt, err := repository.NewTransactionRepositoryWithTx(tx).MarkPurchaseRefunded(ctx, purchase.ID, entryID)
+	if errors.Is(err, repository.ErrPurchaseAlreadyRefunded) {
+		return ErrAlreadyRefunded
+	} else if err != nil {
+		return fmt.Errorf("failed to mark purchase refunded: %w", err)
+	}
+	purchase.RefundedAt = refundedAt.Format(time.RFC3339)
+
+	return releasePromotions(ctx, tx, purchase.ID)
+}
+
+// releasePromotions frees the promotion uses of a refunded or cancelled purchase. The purchase
+// keeps its recorded discount.
+func releasePromotions(ctx context.Context, tx *sql.Tx, purchaseID int) error {
+	return repository.NewPromotionRepositoryWithTx(tx).ReleaseForPurchase(ctx, purchaseID)
+}
+
+// returnToStock puts the item of a cancelled purchase back into stock.
+func returnToStock(ctx context.Context, tx *sql.Tx, purchase model.Purchase) error {
+	merchRepoTx := repository.NewMerchRepositoryWithTx(tx)
+	var err error
+	if purchase.SKU != "" {
+		err = merchRepoTx.ReturnVariantToStock(ctx, purchase.SKU, 1)
+	} else {
+		err = merchRepoTx.ReturnToStock(ctx, purchase.ItemID, 1)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get purchases for user %d: %w", userID, err)
+		return fmt.Errorf("failed to return item to stock: %w", err)
 	}
-	return purchases, nil
+	return nil
 }
 
-func (s *MerchService) CreatePurchaseForUser(ctx context.Context, userID int, itemName string) error {
-	merchItem, err := s.merchRepo.GetMerchItemByName(ctx, itemName)
+// ListOrders returns the orders in status, oldest first.
+func (s *MerchService) ListOrders(ctx context.Context, status string) (_ []model.Purchase, err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.ListOrders", tracing.AttrOrderStatus.String(status))
+	defer func() { tracing.End(span, err) }()
+
+	if !model.IsValidOrderStatus(status) {
+		return nil, ErrInvalidOrderStatus
+	}
+
+	orders, err := s.transactionRepo.ListPurchasesByStatus(ctx, status)
 	if err != nil {
-		return ErrMerchNotFound
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	return orders, nil
+}
+
+// TransitionOrder moves the order to status. Cancelling an order returns the coins to the
+// buyer, the item to stock and the promotion uses in the same transaction as the status change.
+func (s *MerchService) TransitionOrder(ctx context.Context, purchaseID int, status string) (_ *model.Purchase, err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.TransitionOrder",
+		tracing.AttrPurchaseID.Int(purchaseID), tracing.AttrOrderStatus.String(status))
+	defer func() { tracing.End(span, err) }()
+
+	if !model.IsValidOrderStatus(status) {
+		return nil, ErrInvalidOrderStatus
 	}
 
+	var purchase *model.Purchase
+	err = runWithRetry(ctx, func() error {
+		var err error
+		purchase, err = s.transitionOrder(ctx, purchaseID, status)
+		return err
+	})
+	return purchase, err
+}
+
+func (s *MerchService) transitionOrder(ctx context.Context, purchaseID int, status string) (*model.Purchase, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer func() {
 		if p := recover(); p != nil || err != nil {
-			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("failed to rollback transaction: %v", rbErr)
-			}
+			rollback(ctx, s.logger, tx, "MerchService.transitionOrder", err, p)
 		}
 	}()
 
 	transactionRepoTx := repository.NewTransactionRepositoryWithTx(tx)
-	if err = transactionRepoTx.CreatePurchase(ctx, userID, itemName, merchItem.Price); err != nil {
-		return fmt.Errorf("failed to record purchase: %w", err)
+	purchase, err := transactionRepoTx.GetPurchaseForUpdate(ctx, purchaseID)
+	if errors.Is(err, repository.ErrPurchaseNotFound) {
+		err = ErrPurchaseNotFound
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get purchase: %w", err)
+	}
+	if !model.CanTransitionOrder(purchase.Status, status) {
+		err = ErrInvalidOrderTransition
+		return nil, err
+	}
+
+	if status == model.OrderStatusCancelled {
+		// A refunded purchase has been paid back already. Every other one is, including purchases
+		// made before the ledger and ones discounted down to nothing, which only free their promotions.
+		if purchase.RefundedAt == "" {
+			if err = payBackPurchase(ctx, tx, &purchase); err != nil {
+				return nil, err
+			}
+		}
+		if err = returnToStock(ctx, tx, purchase); err != nil {
+			return nil, err
+		}
+	}
+
+	if purchase, err = transactionRepoTx.SetPurchaseStatus(ctx, purchase.ID, status); err != nil {
+		return nil, fmt.Errorf("failed to set order status: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return nil
+	s.logger.InfoContext(ctx, "order status changed", "purchase_id", purchase.ID, "buyer_id", purchase.UserID, "status", status)
+	return &purchase, nil
 }
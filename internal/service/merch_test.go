@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+func newTestMerchService(db *sql.DB) *MerchService {
+	return NewMerchService(repository.NewMerchRepository(db), repository.NewUserRepository(db), db, discardLogger())
+}
+
+// setStock makes itemName stock-tracked with stock units left.
+func setStock(t *testing.T, db *sql.DB, itemName string, stock int) {
+	t.Helper()
+	if _, err := db.Exec("UPDATE merch_items SET stock = $1 WHERE name = $2", stock, itemName); err != nil {
+		t.Fatalf("failed to set stock of %s: %v", itemName, err)
+	}
+}
+
+func itemStock(t *testing.T, db *sql.DB, itemName string) int {
+	t.Helper()
+	item, err := repository.NewMerchRepository(db).GetMerchItemByName(context.Background(), itemName)
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", itemName, err)
+	}
+	if item.Stock == nil {
+		t.Fatalf("%s is not stock-tracked", itemName)
+	}
+	return *item.Stock
+}
+
+// purchaseOne buys itemName for userID and returns the purchase.
+func purchaseOne(t *testing.T, svc *MerchService, userID int, itemName string) model.Purchase {
+	t.Helper()
+	ctx := context.Background()
+	if err := svc.PurchaseMerch(ctx, userID, itemName, "", ""); err != nil {
+		t.Fatalf("PurchaseMerch(%s) error = %v", itemName, err)
+	}
+	purchases, _, err := svc.ListPurchases(ctx, userID, model.PurchaseFilter{})
+	if err != nil || len(purchases) == 0 {
+		t.Fatalf("ListPurchases() = %v, %v, want the purchase", purchases, err)
+	}
+	return purchases[0]
+}
+
+func TestRefundPurchaseReturnsItemToStock(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMerchService(db)
+	buyer := createTestUser(t, db, 1)
+	setStock(t, db, "cup", 3)
+
+	purchase := purchaseOne(t, svc, buyer, "cup")
+	if got := itemStock(t, db, "cup"); got != 2 {
+		t.Fatalf("stock after purchase = %d, want 2", got)
+	}
+
+	refunded, err := svc.RefundPurchase(ctx, purchase.ID)
+	if err != nil {
+		t.Fatalf("RefundPurchase() error = %v", err)
+	}
+	if refunded.Status != model.OrderStatusCancelled {
+		t.Errorf("status after refund = %q, want %q", refunded.Status, model.OrderStatusCancelled)
+	}
+	if got := itemStock(t, db, "cup"); got != 3 {
+		t.Errorf("stock after refund = %d, want 3", got)
+	}
+	if got := userCoins(t, db, buyer); got != 1000 {
+		t.Errorf("buyer has %d coins after refund, want 1000", got)
+	}
+	checkBooks(t, db)
+}
+
+func TestRefundOfDeliveredPurchaseKeepsStock(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMerchService(db)
+	buyer := createTestUser(t, db, 1)
+	setStock(t, db, "cup", 3)
+
+	purchase := purchaseOne(t, svc, buyer, "cup")
+	for _, status := range []string{model.OrderStatusApproved, model.OrderStatusReadyForPickup, model.OrderStatusDelivered} {
+		if _, err := svc.TransitionOrder(ctx, purchase.ID, status); err != nil {
+			t.Fatalf("TransitionOrder(%s) error = %v", status, err)
+		}
+	}
+
+	refunded, err := svc.RefundPurchase(ctx, purchase.ID)
+	if err != nil {
+		t.Fatalf("RefundPurchase() error = %v", err)
+	}
+	if refunded.Status != model.OrderStatusDelivered {
+		t.Errorf("status after refund = %q, want %q", refunded.Status, model.OrderStatusDelivered)
+	}
+	if got := itemStock(t, db, "cup"); got != 2 {
+		t.Errorf("stock after refunding a delivered item = %d, want 2", got)
+	}
+}
+
+func TestRefundPurchaseMadeBeforeTheLedger(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMerchService(db)
+	buyer := createTestUser(t, db, 1)
+
+	purchase := purchaseOne(t, svc, buyer, "cup")
+	// Purchases recorded before the ledger was introduced were paid but have no journal entry.
+	if _, err := db.Exec("UPDATE purchases SET journal_entry_id = NULL WHERE id = $1", purchase.ID); err != nil {
+		t.Fatalf("failed to unlink journal entry: %v", err)
+	}
+
+	if _, err := svc.RefundPurchase(ctx, purchase.ID); err != nil {
+		t.Fatalf("RefundPurchase() error = %v", err)
+	}
+	if got := userCoins(t, db, buyer); got != 1000 {
+		t.Errorf("buyer has %d coins after refund, want 1000", got)
+	}
+	checkBooks(t, db)
+}
+
+func TestRefundFreePurchase(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMerchService(db)
+	buyer := createTestUser(t, db, 1)
+	setStock(t, db, "pen", 3)
+	one := 1
+	free := createTestPromotion(t, db, model.Promotion{Name: "free pen", DiscountType: model.DiscountTypePercent, DiscountValue: 100, ItemName: "pen", MaxUses: &one})
+
+	purchase := purchaseOne(t, svc, buyer, "pen")
+	if purchase.Price != 0 {
+		t.Fatalf("pen price = %d, want 0", purchase.Price)
+	}
+
+	refunded, err := svc.RefundPurchase(ctx, purchase.ID)
+	if err != nil {
+		t.Fatalf("RefundPurchase() error = %v", err)
+	}
+	if refunded.RefundedAt == "" || refunded.Status != model.OrderStatusCancelled {
+		t.Errorf("refund = %+v, want a refunded, cancelled purchase", refunded)
+	}
+	if got := itemStock(t, db, "pen"); got != 3 {
+		t.Errorf("stock after refund = %d, want 3", got)
+	}
+	if uses := promotionUses(t, db, free.ID); uses != 0 {
+		t.Errorf("free pen promotion used %d times after refund, want 0", uses)
+	}
+	if got := userCoins(t, db, buyer); got != 1000 {
+		t.Errorf("buyer has %d coins after refund, want 1000", got)
+	}
+	if _, err := svc.RefundPurchase(ctx, purchase.ID); err != ErrAlreadyRefunded {
+		t.Errorf("second RefundPurchase() error = %v, want %v", err, ErrAlreadyRefunded)
+	}
+	checkBooks(t, db)
+}
+
+func TestCancelOrderReturnsItemToStock(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMerchService(db)
+	buyer := createTestUser(t, db, 1)
+	setStock(t, db, "cup", 3)
+
+	purchase := purchaseOne(t, svc, buyer, "cup")
+	if _, err := svc.TransitionOrder(ctx, purchase.ID, model.OrderStatusCancelled); err != nil {
+		t.Fatalf("TransitionOrder(cancelled) error = %v", err)
+	}
+	if got := itemStock(t, db, "cup"); got != 3 {
+		t.Errorf("stock after cancellation = %d, want 3", got)
+	}
+	if got := userCoins(t, db, buyer); got != 1000 {
+		t.Errorf("buyer has %d coins after cancellation, want 1000", got)
+	}
+	checkBooks(t, db)
+}
+
+func TestCancelOrderMadeBeforeTheLedgerPaysItBack(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMerchService(db)
+	buyer := createTestUser(t, db, 1)
+
+	purchase := purchaseOne(t, svc, buyer, "cup")
+	if _, err := db.Exec("UPDATE purchases SET journal_entry_id = NULL WHERE id = $1", purchase.ID); err != nil {
+		t.Fatalf("failed to unlink journal entry: %v", err)
+	}
+
+	cancelled, err := svc.TransitionOrder(ctx, purchase.ID, model.OrderStatusCancelled)
+	if err != nil {
+		t.Fatalf("TransitionOrder(cancelled) error = %v", err)
+	}
+	if cancelled.RefundedAt == "" {
+		t.Errorf("cancelled order was not refunded")
+	}
+	if got := userCoins(t, db, buyer); got != 1000 {
+		t.Errorf("buyer has %d coins after cancellation, want 1000", got)
+	}
+	checkBooks(t, db)
+}
+
+func TestCancelOrderReturnsRenamedItemToStock(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMerchService(db)
+	buyer := createTestUser(t, db, 1)
+	setStock(t, db, "cup", 3)
+
+	purchase := purchaseOne(t, svc, buyer, "cup")
+	if purchase.ItemID == 0 {
+		t.Fatal("purchase does not reference its merch item")
+	}
+	name := "mug"
+	if _, err := svc.UpdateMerchItem(ctx, purchase.ItemID, model.MerchUpdate{Name: &name}); err != nil {
+		t.Fatalf("UpdateMerchItem() error = %v", err)
+	}
+
+	if _, err := svc.TransitionOrder(ctx, purchase.ID, model.OrderStatusCancelled); err != nil {
+		t.Fatalf("TransitionOrder(cancelled) error = %v", err)
+	}
+	if got := itemStock(t, db, "mug"); got != 3 {
+		t.Errorf("stock after cancelling an order of a renamed item = %d, want 3", got)
+	}
+}
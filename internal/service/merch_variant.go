@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/tracing"
+)
+
+var (
+	ErrVariantNotFound      = errors.New("merch variant not found")
+	ErrVariantAlreadyExists = errors.New("merch variant already exists")
+	ErrInvalidVariant       = errors.New("invalid merch variant")
+	ErrVariantRequired      = errors.New("merch item is sold in variants")
+)
+
+func (s *MerchService) CreateVariant(ctx context.Context, itemID int, variant model.MerchVariant) (_ model.MerchVariant, err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.CreateVariant", tracing.AttrItemID.Int(itemID), tracing.AttrSKU.String(variant.SKU))
+	defer func() { tracing.End(span, err) }()
+
+	variant.SKU = strings.TrimSpace(variant.SKU)
+	variant.Size = strings.TrimSpace(variant.Size)
+	variant.Color = strings.TrimSpace(variant.Color)
+	if variant.SKU == "" || (variant.Size == "" && variant.Color == "") ||
+		(variant.PriceOverride != nil && *variant.PriceOverride <= 0) ||
+		(variant.Stock != nil && *variant.Stock < 0) {
+		return model.MerchVariant{}, ErrInvalidVariant
+	}
+
+	if _, err := s.merchRepo.GetMerchItemByID(ctx, itemID); errors.Is(err, repository.ErrMerchItemNotFound) {
+		return model.MerchVariant{}, ErrMerchNotFound
+	} else if err != nil {
+		return model.MerchVariant{}, fmt.Errorf("failed to get merch item %d: %w", itemID, err)
+	}
+
+	variant.MerchID = itemID
+	variant.Active = true
+	created, err := s.merchRepo.CreateVariant(ctx, variant)
+	if errors.Is(err, repository.ErrMerchVariantExists) {
+		return model.MerchVariant{}, ErrVariantAlreadyExists
+	} else if err != nil {
+		return model.MerchVariant{}, fmt.Errorf("failed to create merch variant: %w", err)
+	}
+	return created, nil
+}
+
+func (s *MerchService) UpdateVariant(ctx context.Context, itemID, variantID int, update model.MerchVariantUpdate) (_ model.MerchVariant, err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.UpdateVariant", tracing.AttrItemID.Int(itemID), tracing.AttrVariantID.Int(variantID))
+	defer func() { tracing.End(span, err) }()
+
+	variant, err := s.merchRepo.GetVariantByID(ctx, itemID, variantID)
+	if errors.Is(err, repository.ErrMerchVariantNotFound) {
+		return model.MerchVariant{}, ErrVariantNotFound
+	} else if err != nil {
+		return model.MerchVariant{}, fmt.Errorf("failed to get merch variant %d: %w", variantID, err)
+	}
+
+	if update.PriceOverride != nil {
+		if *update.PriceOverride < 0 {
+			return model.MerchVariant{}, ErrInvalidVariant
+		}
+		variant.PriceOverride = update.PriceOverride
+		if *update.PriceOverride == 0 {
+			variant.PriceOverride = nil
+		}
+	}
+	if update.Active != nil {
+		variant.Active = *update.Active
+	}
+
+	updated, err := s.merchRepo.UpdateVariant(ctx, variant)
+	if errors.Is(err, repository.ErrMerchVariantNotFound) {
+		return model.MerchVariant{}, ErrVariantNotFound
+	} else if err != nil {
+		return model.MerchVariant{}, fmt.Errorf("failed to update merch variant %d: %w", variantID, err)
+	}
+	return updated, nil
+}
+
+func (s *MerchService) RestockVariant(ctx context.Context, itemID, variantID int, quantity int) (_ model.MerchVariant, err error) {
+	ctx, span := tracing.Start(ctx, "MerchService.RestockVariant", tracing.AttrItemID.Int(itemID), tracing.AttrVariantID.Int(variantID))
+	defer func() { tracing.End(span, err) }()
+
+	if quantity <= 0 {
+		return model.MerchVariant{}, ErrInvalidQuantity
+	}
+
+	variant, err := s.merchRepo.RestockVariant(ctx, itemID, variantID, quantity)
+	if errors.Is(err, repository.ErrMerchVariantNotFound) {
+		return model.MerchVariant{}, ErrVariantNotFound
+	} else if err != nil {
+		return model.MerchVariant{}, fmt.Errorf("failed to restock merch variant %d: %w", variantID, err)
+	}
+	return variant, nil
+}
+
+// resolveVariant returns the variant of item with the given SKU, or nil if the item is bought
+// without one. An item that has active variants cannot be bought without picking one.
+func resolveVariant(ctx context.Context, merchRepo *repository.MerchRepository, item model.Merch, sku string) (*model.MerchVariant, error) {
+	if sku == "" {
+		hasVariants, err := merchRepo.HasActiveVariants(ctx, item.ID)
+		if err != nil {
+			return nil, err
+		}
+		if hasVariants {
+			return nil, ErrVariantRequired
+		}
+		return nil, nil
+	}
+
+	variant, err := merchRepo.GetVariantBySKU(ctx, sku)
+	if errors.Is(err, repository.ErrMerchVariantNotFound) || (err == nil && variant.MerchID != item.ID) {
+		return nil, ErrVariantNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get merch variant: %w", err)
+	}
+	return &variant, nil
+}
+
+// takeStock takes one unit of the variant if there is one, or of the item otherwise.
+func takeStock(ctx context.Context, merchRepoTx *repository.MerchRepository, item model.Merch, variant *model.MerchVariant) error {
+	var err error
+	if variant != nil {
+		err = merchRepoTx.DecrementVariantStock(ctx, variant.ID, 1)
+	} else {
+		err = merchRepoTx.DecrementStock(ctx, item.ID, 1)
+	}
+	if errors.Is(err, repository.ErrMerchOutOfStock) {
+		return ErrOutOfStock
+	} else if err != nil {
+		return fmt.Errorf("failed to decrement stock: %w", err)
+	}
+	return nil
+}
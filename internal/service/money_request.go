@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/metrics"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+var (
+	ErrMoneyRequestNotFound = errors.New("money request not found")
+	ErrMoneyRequestExpired  = errors.New("money request expired")
+	ErrInvalidTransition    = errors.New("money request cannot change to this status")
+	ErrInvalidStatus        = errors.New("invalid status")
+)
+
+type MoneyRequestService struct {
+	moneyRequestRepo *repository.MoneyRequestRepository
+	userRepo         *repository.UserRepository
+	db               *sql.DB
+	// ttl is how long a request stays pending before it expires.
+	ttl    time.Duration
+	logger *slog.Logger
+}
+
+func NewMoneyRequestService(moneyRequestRepo *repository.MoneyRequestRepository, userRepo *repository.UserRepository, db *sql.DB, ttl time.Duration, logger *slog.Logger) *MoneyRequestService {
+	return &MoneyRequestService{
+		moneyRequestRepo: moneyRequestRepo,
+		userRepo:         userRepo,
+		db:               db,
+		ttl:              ttl,
+		logger:           logger,
+	}
+}
+
+// Create asks payerID to pay amount to requesterID.
+func (s *MoneyRequestService) Create(ctx context.Context, requesterID, payerID int, amount int, memo string) (model.MoneyRequest, error) {
+	t := model.Transaction{SenderID: payerID, ReceiverID: requesterID, Amount: amount, Memo: memo}
+	if err := prepareTransfer(&t); err != nil {
+		return model.MoneyRequest{}, err
+	}
+
+	if _, err := s.userRepo.GetCoins(ctx, payerID); errors.Is(err, repository.ErrUserNotFound) {
+		return model.MoneyRequest{}, ErrUserNotFound
+	} else if err != nil {
+		return model.MoneyRequest{}, fmt.Errorf("failed to get payer: %w", err)
+	}
+
+	mr, err := s.moneyRequestRepo.Create(ctx, model.MoneyRequest{
+		RequesterID: requesterID,
+		PayerID:     payerID,
+		Amount:      t.Amount,
+		Memo:        t.Memo,
+		ExpiresAt:   time.Now().Add(s.ttl),
+	})
+	if err != nil {
+		return model.MoneyRequest{}, fmt.Errorf("failed to create money request: %w", err)
+	}
+	return mr, nil
+}
+
+// ListIncoming returns the requests the user is asked to pay, optionally only those in status.
+func (s *MoneyRequestService) ListIncoming(ctx context.Context, payerID int, status string) ([]model.MoneyRequest, error) {
+	if status != "" && !model.IsValidMoneyRequestStatus(status) {
+		return nil, ErrInvalidStatus
+	}
+	if err := s.moneyRequestRepo.ExpirePending(ctx, payerID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	requests, err := s.moneyRequestRepo.ListByPayer(ctx, payerID, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list incoming money requests: %w", err)
+	}
+	return requests, nil
+}
+
+// ListOutgoing returns the requests the user created, optionally only those in status.
+func (s *MoneyRequestService) ListOutgoing(ctx context.Context, requesterID int, status string) ([]model.MoneyRequest, error) {
+	if status != "" && !model.IsValidMoneyRequestStatus(status) {
+		return nil, ErrInvalidStatus
+	}
+	if err := s.moneyRequestRepo.ExpirePending(ctx, requesterID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	requests, err := s.moneyRequestRepo.ListByRequester(ctx, requesterID, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outgoing money requests: %w", err)
+	}
+	return requests, nil
+}
+
+// Accept pays the request: the transfer and the status change happen in one transaction.
+func (s *MoneyRequestService) Accept(ctx context.Context, payerID int, id int) (*model.MoneyRequest, error) {
+	var mr *model.MoneyRequest
+	err := runWithRetry(ctx, func() error {
+		var err error
+		mr, err = s.resolve(ctx, id, payerID, model.MoneyRequestAccepted)
+		return err
+	})
+	if errors.Is(err, ErrInsufficientFunds) {
+		metrics.InsufficientFunds(metrics.OperationTransfer)
+	}
+	return mr, err
+}
+
+func (s *MoneyRequestService) Decline(ctx context.Context, payerID int, id int) (*model.MoneyRequest, error) {
+	return s.resolve(ctx, id, payerID, model.MoneyRequestDeclined)
+}
+
+func (s *MoneyRequestService) Cancel(ctx context.Context, requesterID int, id int) (*model.MoneyRequest, error) {
+	return s.resolve(ctx, id, requesterID, model.MoneyRequestCancelled)
+}
+
+// resolve moves the request to status on behalf of userID. The payer accepts or declines,
+// the requester cancels. A pending request found past its expiry is marked expired instead.
+func (s *MoneyRequestService) resolve(ctx context.Context, id int, userID int, status string) (*model.MoneyRequest, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil || err != nil {
+			rollback(ctx, s.logger, tx, "MoneyRequestService.resolve", err, p)
+		}
+	}()
+
+	moneyRequestRepoTx := repository.NewMoneyRequestRepositoryWithTx(tx)
+
+	mr, err := moneyRequestRepoTx.GetForUpdate(ctx, id)
+	if errors.Is(err, repository.ErrMoneyRequestNotFound) {
+		err = ErrMoneyRequestNotFound
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get money request: %w", err)
+	}
+
+	actorID := mr.PayerID
+	if status == model.MoneyRequestCancelled {
+		actorID = mr.RequesterID
+	}
+	if actorID != userID {
+		err = ErrMoneyRequestNotFound
+		return nil, err
+	}
+
+	if mr.Status == model.MoneyRequestPending && !time.Now().Before(mr.ExpiresAt) {
+		if _, err = moneyRequestRepoTx.Resolve(ctx, mr.ID, model.MoneyRequestExpired, 0); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil, ErrMoneyRequestExpired
+	}
+
+	if !model.CanTransitionMoneyRequest(mr.Status, status) {
+		err = ErrInvalidTransition
+		return nil, err
+	}
+
+	transactionID := 0
+	if status == model.MoneyRequestAccepted {
+		t := model.Transaction{SenderID: mr.PayerID, ReceiverID: mr.RequesterID, Amount: mr.Amount, Memo: mr.Memo}
+		if err = prepareTransfer(&t); err != nil {
+			return nil, err
+		}
+		if transactionID, err = executeTransfer(ctx, tx, t); err != nil {
+			return nil, err
+		}
+	}
+
+	resolved, err := moneyRequestRepoTx.Resolve(ctx, mr.ID, status, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if status == model.MoneyRequestAccepted {
+		metrics.TransferCompleted(resolved.Amount)
+	}
+	return &resolved, nil
+}
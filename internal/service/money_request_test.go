@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+func newTestMoneyRequestService(db *sql.DB, ttl time.Duration) *MoneyRequestService {
+	return NewMoneyRequestService(repository.NewMoneyRequestRepository(db), repository.NewUserRepository(db), db, ttl, discardLogger())
+}
+
+func TestMoneyRequestAcceptMovesMoney(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMoneyRequestService(db, time.Hour)
+	requester, payer := createTestUser(t, db, 1), createTestUser(t, db, 2)
+
+	mr, err := svc.Create(ctx, requester, payer, 150, "lunch")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := svc.Accept(ctx, requester, mr.ID); !errors.Is(err, ErrMoneyRequestNotFound) {
+		t.Errorf("Accept() by the requester error = %v, want %v", err, ErrMoneyRequestNotFound)
+	}
+
+	accepted, err := svc.Accept(ctx, payer, mr.ID)
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	if accepted.Status != model.MoneyRequestAccepted || accepted.TransactionID == 0 || accepted.ResolvedAt == nil {
+		t.Errorf("Accept() = %+v, want accepted with a transaction", accepted)
+	}
+	if got := userCoins(t, db, requester); got != 1150 {
+		t.Errorf("requester has %d coins, want 1150", got)
+	}
+	if got := userCoins(t, db, payer); got != 850 {
+		t.Errorf("payer has %d coins, want 850", got)
+	}
+
+	if _, err := svc.Accept(ctx, payer, mr.ID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("second Accept() error = %v, want %v", err, ErrInvalidTransition)
+	}
+	if got := userCoins(t, db, payer); got != 850 {
+		t.Errorf("payer has %d coins after accepting twice, want 850", got)
+	}
+	checkBooks(t, db)
+}
+
+func TestMoneyRequestAcceptWithoutFundsStaysPending(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMoneyRequestService(db, time.Hour)
+	requester, payer := createTestUser(t, db, 1), createTestUser(t, db, 2)
+
+	mr, err := svc.Create(ctx, requester, payer, 1001, "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := svc.Accept(ctx, payer, mr.ID); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Accept() error = %v, want %v", err, ErrInsufficientFunds)
+	}
+	pending, err := svc.ListIncoming(ctx, payer, model.MoneyRequestPending)
+	if err != nil || len(pending) != 1 {
+		t.Errorf("ListIncoming(pending) = %v, %v, want the request", pending, err)
+	}
+}
+
+func TestMoneyRequestDecline(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMoneyRequestService(db, time.Hour)
+	requester, payer := createTestUser(t, db, 1), createTestUser(t, db, 2)
+
+	mr, err := svc.Create(ctx, requester, payer, 150, "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := svc.Decline(ctx, requester, mr.ID); !errors.Is(err, ErrMoneyRequestNotFound) {
+		t.Errorf("Decline() by the requester error = %v, want %v", err, ErrMoneyRequestNotFound)
+	}
+
+	declined, err := svc.Decline(ctx, payer, mr.ID)
+	if err != nil {
+		t.Fatalf("Decline() error = %v", err)
+	}
+	if declined.Status != model.MoneyRequestDeclined || declined.TransactionID != 0 {
+		t.Errorf("Decline() = %+v, want declined without a transaction", declined)
+	}
+	if _, err := svc.Accept(ctx, payer, mr.ID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Accept() after Decline() error = %v, want %v", err, ErrInvalidTransition)
+	}
+	if got := userCoins(t, db, payer); got != 1000 {
+		t.Errorf("payer has %d coins, want 1000", got)
+	}
+}
+
+func TestMoneyRequestCancelOnlyByRequester(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMoneyRequestService(db, time.Hour)
+	requester, payer, other := createTestUser(t, db, 1), createTestUser(t, db, 2), createTestUser(t, db, 3)
+
+	mr, err := svc.Create(ctx, requester, payer, 150, "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	for _, userID := range []int{payer, other} {
+		if _, err := svc.Cancel(ctx, userID, mr.ID); !errors.Is(err, ErrMoneyRequestNotFound) {
+			t.Errorf("Cancel() by user %d error = %v, want %v", userID, err, ErrMoneyRequestNotFound)
+		}
+	}
+
+	cancelled, err := svc.Cancel(ctx, requester, mr.ID)
+	if err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if cancelled.Status != model.MoneyRequestCancelled {
+		t.Errorf("Cancel() status = %q, want %q", cancelled.Status, model.MoneyRequestCancelled)
+	}
+	if _, err := svc.Accept(ctx, payer, mr.ID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Accept() after Cancel() error = %v, want %v", err, ErrInvalidTransition)
+	}
+}
+
+func TestMoneyRequestExpiresLazily(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMoneyRequestService(db, time.Millisecond)
+	requester, payer := createTestUser(t, db, 1), createTestUser(t, db, 2)
+
+	accepted, err := svc.Create(ctx, requester, payer, 150, "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	listed, err := svc.Create(ctx, requester, payer, 50, "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := svc.Accept(ctx, payer, accepted.ID); !errors.Is(err, ErrMoneyRequestExpired) {
+		t.Errorf("Accept() of an expired request error = %v, want %v", err, ErrMoneyRequestExpired)
+	}
+	if got := userCoins(t, db, payer); got != 1000 {
+		t.Errorf("payer has %d coins, want 1000", got)
+	}
+
+	expired, err := svc.ListOutgoing(ctx, requester, model.MoneyRequestExpired)
+	if err != nil {
+		t.Fatalf("ListOutgoing() error = %v", err)
+	}
+	ids := map[int]bool{}
+	for _, mr := range expired {
+		ids[mr.ID] = true
+	}
+	if len(expired) != 2 || !ids[accepted.ID] || !ids[listed.ID] {
+		t.Errorf("ListOutgoing(expired) = %+v, want both requests", expired)
+	}
+	if _, err := svc.Cancel(ctx, requester, listed.ID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Cancel() of an expired request error = %v, want %v", err, ErrInvalidTransition)
+	}
+}
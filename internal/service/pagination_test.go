@@ -0,0 +1,92 @@
+package service
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := &model.Cursor{
+		CreatedAt: time.Date(2025, 2, 14, 9, 30, 15, 123456000, time.FixedZone("MSK", 3*60*60)),
+		ID:        42,
+	}
+	encoded := EncodeCursor(want)
+	if encoded == "" {
+		t.Fatal("EncodeCursor() returned an empty string")
+	}
+
+	got, err := DecodeCursor(encoded)
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+	if got.ID != want.ID || !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("DecodeCursor(EncodeCursor(%+v)) = %+v", want, got)
+	}
+}
+
+func TestEncodeCursorOfLastPage(t *testing.T) {
+	if got := EncodeCursor(nil); got != "" {
+		t.Errorf("EncodeCursor(nil) = %q, want empty", got)
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"first page", "", false},
+		{"valid", encode(`{"t":"2025-02-14T09:30:15Z","id":7}`), false},
+		{"not base64", "not a cursor!", true},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"t":"2025-02-14T09:30:15Z","id":7}`)), true},
+		{"not json", encode("id=7"), true},
+		{"zero id", encode(`{"t":"2025-02-14T09:30:15Z","id":0}`), true},
+		{"negative id", encode(`{"t":"2025-02-14T09:30:15Z","id":-3}`), true},
+		{"missing time", encode(`{"id":7}`), true},
+		{"bad time", encode(`{"t":"yesterday","id":7}`), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := DecodeCursor(tt.value)
+			if tt.wantErr {
+				if err != ErrInvalidCursor {
+					t.Errorf("DecodeCursor(%q) error = %v, want %v", tt.value, err, ErrInvalidCursor)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeCursor(%q) error = %v", tt.value, err)
+			}
+			if tt.value == "" && cursor != nil {
+				t.Errorf("DecodeCursor(\"\") = %+v, want nil", cursor)
+			}
+			if tt.value != "" && cursor == nil {
+				t.Errorf("DecodeCursor(%q) = nil", tt.value)
+			}
+		})
+	}
+}
+
+func TestNormalizePage(t *testing.T) {
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{0, defaultPageSize},
+		{-5, defaultPageSize},
+		{1, 1},
+		{maxPageSize, maxPageSize},
+		{maxPageSize + 1, maxPageSize},
+	}
+	for _, tt := range tests {
+		if got := normalizePage(model.Page{Limit: tt.limit}).Limit; got != tt.want {
+			t.Errorf("normalizePage(Limit: %d).Limit = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+var (
+	ErrInvalidPromotion       = errors.New("invalid promotion")
+	ErrPromotionNotFound      = errors.New("promotion not found")
+	ErrPromoCodeAlreadyExists = errors.New("promo code already exists")
+	ErrPromoCodeInvalid       = errors.New("promo code is not valid")
+	ErrPromoCodeNotApplicable = errors.New("promo code does not apply to this item or another promotion gives more")
+	ErrPromoCodeExhausted     = errors.New("promo code usage limit reached")
+)
+
+type PromotionService struct {
+	promotionRepo *repository.PromotionRepository
+}
+
+func NewPromotionService(promotionRepo *repository.PromotionRepository) *PromotionService {
+	return &PromotionService{promotionRepo: promotionRepo}
+}
+
+func (s *PromotionService) Create(ctx context.Context, p model.Promotion) (model.Promotion, error) {
+	p.Code = strings.TrimSpace(p.Code)
+	p.Name = strings.TrimSpace(p.Name)
+	p.ItemName = strings.TrimSpace(p.ItemName)
+	p.Category = strings.TrimSpace(p.Category)
+	if p.StartsAt.IsZero() {
+		p.StartsAt = time.Now()
+	}
+
+	if p.Name == "" || p.DiscountValue <= 0 ||
+		(p.DiscountType != model.DiscountTypePercent && p.DiscountType != model.DiscountTypeFixed) ||
+		(p.DiscountType == model.DiscountTypePercent && p.DiscountValue > 100) ||
+		(p.ItemName != "" && p.Category != "") ||
+		(p.EndsAt != nil && !p.EndsAt.After(p.StartsAt)) ||
+		(p.MaxUses != nil && *p.MaxUses <= 0) ||
+		(p.MaxUsesPerUser != nil && *p.MaxUsesPerUser <= 0) {
+		return model.Promotion{}, ErrInvalidPromotion
+	}
+
+	created, err := s.promotionRepo.Create(ctx, p)
+	if errors.Is(err, repository.ErrPromotionCodeExists) {
+		return model.Promotion{}, ErrPromoCodeAlreadyExists
+	} else if err != nil {
+		return model.Promotion{}, fmt.Errorf("failed to create promotion: %w", err)
+	}
+	return created, nil
+}
+
+func (s *PromotionService) List(ctx context.Context) ([]model.Promotion, error) {
+	promotions, err := s.promotionRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list promotions: %w", err)
+	}
+	return promotions, nil
+}
+
+// Deactivate ends the promotion. Purchases already made with it keep their discount.
+func (s *PromotionService) Deactivate(ctx context.Context, id int) (model.Promotion, error) {
+	p, err := s.promotionRepo.Deactivate(ctx, id)
+	if errors.Is(err, repository.ErrPromotionNotFound) {
+		return model.Promotion{}, ErrPromotionNotFound
+	} else if err != nil {
+		return model.Promotion{}, fmt.Errorf("failed to deactivate promotion %d: %w", id, err)
+	}
+	return p, nil
+}
+
+// applyPromotions locks the promotions that may discount the purchase of item at price and picks the
+// best discount for the buyer. An explicitly entered code that cannot be used is an error, and so is one
+// left out because a non-stackable promotion gives more on its own: the buyer is told rather than
+// charged as if they had entered nothing. Automatic promotions that cannot be used are skipped.
+// The caller records the returned redemptions with the purchase.
+func applyPromotions(ctx context.Context, tx *sql.Tx, userID int, item model.Merch, price int, code string) ([]model.Redemption, int, error) {
+	promotionRepoTx := repository.NewPromotionRepositoryWithTx(tx)
+	promotions, err := promotionRepoTx.LockForPurchase(ctx, code, item)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	codeID := 0
+	eligible := make([]model.Promotion, 0, len(promotions))
+	for _, p := range promotions {
+		entered := code != "" && p.Code == code
+		if entered {
+			codeID = p.ID
+			if !p.Active || !p.InWindow(now) {
+				return nil, 0, ErrPromoCodeInvalid
+			}
+			if !p.AppliesTo(item) {
+				return nil, 0, ErrPromoCodeNotApplicable
+			}
+		}
+
+		available := p.MaxUses == nil || p.Uses < *p.MaxUses
+		if available && p.MaxUsesPerUser != nil {
+			used, err := promotionRepoTx.CountUserRedemptions(ctx, p.ID, userID)
+			if err != nil {
+				return nil, 0, err
+			}
+			available = used < *p.MaxUsesPerUser
+		}
+		if !available {
+			if entered {
+				return nil, 0, ErrPromoCodeExhausted
+			}
+			continue
+		}
+		eligible = append(eligible, p)
+	}
+	if code != "" && codeID == 0 {
+		return nil, 0, ErrPromoCodeInvalid
+	}
+
+	redemptions, discount := chooseRedemptions(eligible, price, codeID)
+	if codeID != 0 && !redeems(redemptions, codeID) {
+		return nil, 0, ErrPromoCodeNotApplicable
+	}
+	return redemptions, discount, nil
+}
+
+func redeems(redemptions []model.Redemption, promotionID int) bool {
+	for _, r := range redemptions {
+		if r.PromotionID == promotionID {
+			return true
+		}
+	}
+	return false
+}
+
+// chooseRedemptions compares all stackable promotions taken together with every other promotion
+// taken alone, and returns whichever gives the larger discount off price. On a tie the choice
+// that includes preferredID, the promotion of an entered code, wins.
+func chooseRedemptions(promotions []model.Promotion, price int, preferredID int) ([]model.Redemption, int) {
+	var best []model.Redemption
+	bestDiscount := 0
+	better := func(discount int, preferred bool) bool {
+		return discount > bestDiscount || (discount > 0 && discount == bestDiscount && preferred)
+	}
+
+	var stacked []model.Redemption
+	remaining := price
+	for _, p := range promotions {
+		if !p.Stackable {
+			if discount := p.Discount(price); better(discount, p.ID == preferredID) {
+				best = []model.Redemption{{PromotionID: p.ID, Discount: discount}}
+				bestDiscount = discount
+			}
+			continue
+		}
+		// Stacked discounts are each computed off the list price and together never exceed it.
+		discount := p.Discount(price)
+		if discount > remaining {
+			discount = remaining
+		}
+		if discount > 0 {
+			stacked = append(stacked, model.Redemption{PromotionID: p.ID, Discount: discount})
+			remaining -= discount
+		}
+	}
+	if better(price-remaining, redeems(stacked, preferredID)) {
+		best = stacked
+		bestDiscount = price - remaining
+	}
+	return best, bestDiscount
+}
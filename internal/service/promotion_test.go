@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+func TestChooseRedemptions(t *testing.T) {
+	percent := func(id, value int, stackable bool) model.Promotion {
+		return model.Promotion{ID: id, DiscountType: model.DiscountTypePercent, DiscountValue: value, Stackable: stackable}
+	}
+	fixed := func(id, value int, stackable bool) model.Promotion {
+		return model.Promotion{ID: id, DiscountType: model.DiscountTypeFixed, DiscountValue: value, Stackable: stackable}
+	}
+
+	tests := []struct {
+		name         string
+		promotions   []model.Promotion
+		price        int
+		preferredID  int
+		wantIDs      []int
+		wantDiscount int
+	}{
+		{"none", nil, 100, 0, nil, 0},
+		{"best single", []model.Promotion{percent(1, 10, false), fixed(2, 30, false)}, 100, 0, []int{2}, 30},
+		{"stack beats single", []model.Promotion{fixed(1, 20, true), percent(2, 15, true), fixed(3, 30, false)}, 100, 0, []int{1, 2}, 35},
+		{"single beats stack", []model.Promotion{fixed(1, 10, true), fixed(2, 10, true), percent(3, 50, false)}, 100, 0, []int{3}, 50},
+		{"stack never exceeds price", []model.Promotion{fixed(1, 70, true), fixed(2, 70, true)}, 100, 0, []int{1, 2}, 100},
+		{"first of equal singles", []model.Promotion{fixed(1, 20, false), fixed(2, 20, false)}, 100, 0, []int{1}, 20},
+		{"preferred single wins a tie", []model.Promotion{fixed(1, 20, false), fixed(2, 20, false)}, 100, 2, []int{2}, 20},
+		{"preferred stack wins a tie", []model.Promotion{fixed(1, 20, false), fixed(2, 20, true)}, 100, 2, []int{2}, 20},
+		{"preferred loses to a larger discount", []model.Promotion{fixed(1, 30, false), fixed(2, 20, true)}, 100, 2, []int{1}, 30},
+		{"zero discount is no redemption", []model.Promotion{percent(1, 10, false)}, 5, 0, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redemptions, discount := chooseRedemptions(tt.promotions, tt.price, tt.preferredID)
+			if discount != tt.wantDiscount {
+				t.Errorf("discount = %d, want %d", discount, tt.wantDiscount)
+			}
+			var ids []int
+			total := 0
+			for _, r := range redemptions {
+				ids = append(ids, r.PromotionID)
+				total += r.Discount
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("redeemed %v, want %v", ids, tt.wantIDs)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Errorf("redeemed %v, want %v", ids, tt.wantIDs)
+					break
+				}
+			}
+			if total != discount {
+				t.Errorf("redemptions sum to %d, discount is %d", total, discount)
+			}
+		})
+	}
+}
+
+func createTestPromotion(t *testing.T, db *sql.DB, p model.Promotion) model.Promotion {
+	t.Helper()
+	created, err := NewPromotionService(repository.NewPromotionRepository(db)).Create(context.Background(), p)
+	if err != nil {
+		t.Fatalf("failed to create promotion %s: %v", p.Name, err)
+	}
+	return created
+}
+
+func promotionUses(t *testing.T, db *sql.DB, id int) int {
+	t.Helper()
+	var uses int
+	if err := db.QueryRow("SELECT uses FROM promotions WHERE id = $1", id).Scan(&uses); err != nil {
+		t.Fatalf("failed to get uses of promotion %d: %v", id, err)
+	}
+	return uses
+}
+
+func TestPurchaseRejectsCodeBeatenByAutomaticPromotion(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMerchService(db)
+	buyer := createTestUser(t, db, 1)
+	createTestPromotion(t, db, model.Promotion{Name: "cup sale", DiscountType: model.DiscountTypePercent, DiscountValue: 50, ItemName: "cup"})
+	createTestPromotion(t, db, model.Promotion{Name: "small", Code: "SMALL", DiscountType: model.DiscountTypeFixed, DiscountValue: 2})
+
+	if err := svc.PurchaseMerch(ctx, buyer, "cup", "", "SMALL"); !errors.Is(err, ErrPromoCodeNotApplicable) {
+		t.Errorf("PurchaseMerch() error = %v, want %v", err, ErrPromoCodeNotApplicable)
+	}
+	if got := userCoins(t, db, buyer); got != 1000 {
+		t.Errorf("buyer has %d coins, want 1000", got)
+	}
+}
+
+func TestCheckoutAppliesPromotions(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	cartService := newTestCartService(db)
+	buyer := createTestUser(t, db, 1)
+	sale := createTestPromotion(t, db, model.Promotion{Name: "cup sale", DiscountType: model.DiscountTypeFixed, DiscountValue: 5, ItemName: "cup"})
+	one := 1
+	code := createTestPromotion(t, db, model.Promotion{Name: "book code", Code: "BOOK20", DiscountType: model.DiscountTypeFixed, DiscountValue: 20, ItemName: "book", MaxUsesPerUser: &one})
+
+	for item, quantity := range map[string]int{"cup": 2, "book": 2} {
+		if _, err := cartService.AddItem(ctx, buyer, item, quantity); err != nil {
+			t.Fatalf("AddItem(%s) error = %v", item, err)
+		}
+	}
+
+	// cups 2 * (20 - 5), books 50 - 20 and 50: the code is used up after the first book.
+	cart, err := cartService.Checkout(ctx, buyer, "BOOK20")
+	if err != nil {
+		t.Fatalf("Checkout() error = %v", err)
+	}
+	if cart.Total != 110 || cart.Discount != 30 {
+		t.Errorf("Checkout() total %d discount %d, want 110 and 30", cart.Total, cart.Discount)
+	}
+	if got := userCoins(t, db, buyer); got != 890 {
+		t.Errorf("buyer has %d coins, want 890", got)
+	}
+	if uses := promotionUses(t, db, sale.ID); uses != 2 {
+		t.Errorf("cup sale used %d times, want 2", uses)
+	}
+	if uses := promotionUses(t, db, code.ID); uses != 1 {
+		t.Errorf("code used %d times, want 1", uses)
+	}
+	checkBooks(t, db)
+}
+
+func TestCheckoutRejectsCodeThatAppliesToNoItem(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	cartService := newTestCartService(db)
+	buyer := createTestUser(t, db, 1)
+	createTestPromotion(t, db, model.Promotion{Name: "book code", Code: "BOOK20", DiscountType: model.DiscountTypeFixed, DiscountValue: 20, ItemName: "book"})
+
+	if _, err := cartService.AddItem(ctx, buyer, "cup", 1); err != nil {
+		t.Fatalf("AddItem() error = %v", err)
+	}
+	if _, err := cartService.Checkout(ctx, buyer, "BOOK20"); !errors.Is(err, ErrPromoCodeNotApplicable) {
+		t.Errorf("Checkout() error = %v, want %v", err, ErrPromoCodeNotApplicable)
+	}
+	if _, err := cartService.Checkout(ctx, buyer, "NOPE"); !errors.Is(err, ErrPromoCodeInvalid) {
+		t.Errorf("Checkout() with an unknown code error = %v, want %v", err, ErrPromoCodeInvalid)
+	}
+	if got := userCoins(t, db, buyer); got != 1000 {
+		t.Errorf("buyer has %d coins, want 1000", got)
+	}
+}
+
+func TestRefundAndCancelReleasePromotions(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestMerchService(db)
+	buyer := createTestUser(t, db, 1)
+	one := 1
+	code := createTestPromotion(t, db, model.Promotion{Name: "once", Code: "ONCE", DiscountType: model.DiscountTypeFixed, DiscountValue: 5, MaxUses: &one})
+	free := createTestPromotion(t, db, model.Promotion{Name: "free pen", DiscountType: model.DiscountTypePercent, DiscountValue: 100, ItemName: "pen", MaxUses: &one})
+
+	if err := svc.PurchaseMerch(ctx, buyer, "cup", "", "ONCE"); err != nil {
+		t.Fatalf("PurchaseMerch() error = %v", err)
+	}
+	pen := purchaseOne(t, svc, buyer, "pen")
+	purchases, _, err := svc.ListPurchases(ctx, buyer, model.PurchaseFilter{})
+	if err != nil || len(purchases) != 2 {
+		t.Fatalf("ListPurchases() = %v, %v, want two purchases", purchases, err)
+	}
+	cup := purchases[1]
+	if pen.Price != 0 || cup.Discount != 5 {
+		t.Fatalf("pen price %d and cup discount %d, want 0 and 5", pen.Price, cup.Discount)
+	}
+
+	if _, err := svc.RefundPurchase(ctx, cup.ID); err != nil {
+		t.Fatalf("RefundPurchase() error = %v", err)
+	}
+	if uses := promotionUses(t, db, code.ID); uses != 0 {
+		t.Errorf("code used %d times after refund, want 0", uses)
+	}
+	if err := svc.PurchaseMerch(ctx, buyer, "cup", "", "ONCE"); err != nil {
+		t.Errorf("PurchaseMerch() with the released code error = %v", err)
+	}
+
+	if _, err := svc.TransitionOrder(ctx, pen.ID, model.OrderStatusCancelled); err != nil {
+		t.Fatalf("TransitionOrder(cancelled) error = %v", err)
+	}
+	if uses := promotionUses(t, db, free.ID); uses != 0 {
+		t.Errorf("free pen promotion used %d times after cancellation, want 0", uses)
+	}
+	checkBooks(t, db)
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+const (
+	maxTxAttempts  = 3
+	txRetryBackoff = 10 * time.Millisecond
+)
+
+// runWithRetry runs fn again when Postgres aborts its transaction because of a deadlock
+// or a serialization failure. fn must begin and finish its own transaction.
+func runWithRetry(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = fn()
+		if err == nil || !repository.IsRetryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * txRetryBackoff):
+		}
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestRunWithRetry(t *testing.T) {
+	deadlock := &pq.Error{Code: "40P01"}
+	errOther := errors.New("insufficient funds")
+
+	tests := []struct {
+		name         string
+		errs         []error // returned by successive attempts; nil once exhausted
+		wantErr      error
+		wantAttempts int
+	}{
+		{"succeeds first time", nil, nil, 1},
+		{"retries a deadlock", []error{deadlock}, nil, 2},
+		{"retries a serialization failure", []error{&pq.Error{Code: "40001"}, deadlock}, nil, 3},
+		{"gives up after the last attempt", []error{deadlock, deadlock, deadlock, nil}, deadlock, maxTxAttempts},
+		{"does not retry other errors", []error{errOther, nil}, errOther, 1},
+		{"stops at a non-retryable error after a retry", []error{deadlock, errOther}, errOther, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := runWithRetry(context.Background(), func() error {
+				attempts++
+				if attempts > len(tt.errs) {
+					return nil
+				}
+				return tt.errs[attempts-1]
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("runWithRetry() error = %v, want %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("fn ran %d times, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRunWithRetryStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	err := runWithRetry(ctx, func() error {
+		attempts++
+		cancel()
+		return &pq.Error{Code: "40P01"}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("runWithRetry() error = %v, want %v", err, context.Canceled)
+	}
+	if attempts != 1 {
+		t.Errorf("fn ran %d times, want 1", attempts)
+	}
+}
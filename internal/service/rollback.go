@@ -0,0 +1,22 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+)
+
+// rollback rolls tx back and logs if that fails. op names the aborted operation; cause and
+// panicked are the error and the recovered panic that aborted it, either of which may be nil.
+func rollback(ctx context.Context, logger *slog.Logger, tx *sql.Tx, op string, cause error, panicked interface{}) {
+	if err := tx.Rollback(); err != nil {
+		attrs := []slog.Attr{slog.String("op", op), slog.Any("error", err)}
+		if cause != nil {
+			attrs = append(attrs, slog.Any("cause", cause))
+		}
+		if panicked != nil {
+			attrs = append(attrs, slog.Any("panic", panicked))
+		}
+		logger.LogAttrs(ctx, slog.LevelError, "failed to rollback transaction", attrs...)
+	}
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+var (
+	ErrScheduledTransferNotFound = errors.New("scheduled transfer not found")
+	ErrInvalidSchedule           = errors.New("invalid schedule")
+	ErrScheduleStatusConflict    = errors.New("scheduled transfer cannot change to this status")
+)
+
+const (
+	MinScheduleInterval = time.Minute
+	scheduleClaimLimit  = 50
+)
+
+type ScheduleService struct {
+	scheduleRepo  *repository.ScheduledTransferRepository
+	userRepo      *repository.UserRepository
+	walletService *WalletService
+	db            *sql.DB
+	logger        *slog.Logger
+
+	// mu guards the state of Run reported by Health.
+	mu           sync.Mutex
+	pollInterval time.Duration
+	lastPoll     time.Time
+	lastPollErr  error
+}
+
+func NewScheduleService(scheduleRepo *repository.ScheduledTransferRepository, userRepo *repository.UserRepository, walletService *WalletService, db *sql.DB, logger *slog.Logger) *ScheduleService {
+	return &ScheduleService{
+		scheduleRepo:  scheduleRepo,
+		userRepo:      userRepo,
+		walletService: walletService,
+		db:            db,
+		logger:        logger,
+	}
+}
+
+func (s *ScheduleService) Create(ctx context.Context, st model.ScheduledTransfer) (model.ScheduledTransfer, error) {
+	t := model.Transaction{SenderID: st.SenderID, ReceiverID: st.ReceiverID, Amount: st.Amount, Memo: st.Memo, Category: st.Category}
+	if err := prepareTransfer(&t); err != nil {
+		return model.ScheduledTransfer{}, err
+	}
+	st.Memo, st.Category = t.Memo, t.Category
+
+	if st.NextRunAt.IsZero() || st.NextRunAt.Before(time.Now()) {
+		return model.ScheduledTransfer{}, ErrInvalidSchedule
+	}
+	if st.IntervalSeconds < 0 || (st.IntervalSeconds > 0 && time.Duration(st.IntervalSeconds)*time.Second < MinScheduleInterval) {
+		return model.ScheduledTransfer{}, ErrInvalidSchedule
+	}
+
+	if _, err := s.userRepo.GetCoins(ctx, st.ReceiverID); errors.Is(err, repository.ErrUserNotFound) {
+		return model.ScheduledTransfer{}, ErrUserNotFound
+	} else if err != nil {
+		return model.ScheduledTransfer{}, fmt.Errorf("failed to get receiver: %w", err)
+	}
+
+	created, err := s.scheduleRepo.Create(ctx, st)
+	if err != nil {
+		return model.ScheduledTransfer{}, fmt.Errorf("failed to create scheduled transfer: %w", err)
+	}
+	return created, nil
+}
+
+func (s *ScheduleService) List(ctx context.Context, senderID int) ([]model.ScheduledTransfer, error) {
+	transfers, err := s.scheduleRepo.ListBySender(ctx, senderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled transfers: %w", err)
+	}
+	return transfers, nil
+}
+
+func (s *ScheduleService) Pause(ctx context.Context, id, senderID int) (model.ScheduledTransfer, error) {
+	return s.setStatus(ctx, id, senderID, []string{model.ScheduleStatusActive}, model.ScheduleStatusPaused)
+}
+
+// Resume reactivates a paused transfer. Occurrences missed while paused are not caught up:
+// the next poll runs the transfer once and moves it to the next future occurrence.
+func (s *ScheduleService) Resume(ctx context.Context, id, senderID int) (model.ScheduledTransfer, error) {
+	return s.setStatus(ctx, id, senderID, []string{model.ScheduleStatusPaused}, model.ScheduleStatusActive)
+}
+
+func (s *ScheduleService) Cancel(ctx context.Context, id, senderID int) (model.ScheduledTransfer, error) {
+	return s.setStatus(ctx, id, senderID, []string{model.ScheduleStatusActive, model.ScheduleStatusPaused}, model.ScheduleStatusCancelled)
+}
+
+func (s *ScheduleService) setStatus(ctx context.Context, id, senderID int, from []string, status string) (model.ScheduledTransfer, error) {
+	st, err := s.scheduleRepo.SetStatus(ctx, id, senderID, from, status)
+	if errors.Is(err, repository.ErrScheduledTransferNotFound) {
+		return model.ScheduledTransfer{}, ErrScheduledTransferNotFound
+	} else if errors.Is(err, repository.ErrScheduleStatusConflict) {
+		return model.ScheduledTransfer{}, ErrScheduleStatusConflict
+	} else if err != nil {
+		return model.ScheduledTransfer{}, fmt.Errorf("failed to set scheduled transfer status: %w", err)
+	}
+	return st, nil
+}
+
+// ListRuns returns the run history of the sender's scheduled transfer, including failures.
+func (s *ScheduleService) ListRuns(ctx context.Context, id, senderID int) ([]model.ScheduledTransferRun, error) {
+	if _, err := s.scheduleRepo.GetBySender(ctx, id, senderID); errors.Is(err, repository.ErrScheduledTransferNotFound) {
+		return nil, ErrScheduledTransferNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled transfer: %w", err)
+	}
+
+	runs, err := s.scheduleRepo.ListRuns(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled transfer runs: %w", err)
+	}
+	return runs, nil
+}
+
+// Run polls for due transfers every pollInterval until ctx is cancelled.
+func (s *ScheduleService) Run(ctx context.Context, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	s.mu.Lock()
+	s.pollInterval = pollInterval
+	s.mu.Unlock()
+
+	for {
+		// A batch that has started is finished even if ctx is cancelled meanwhile:
+		// its occurrences are already claimed and would not be retried.
+		_, err := s.RunDue(context.WithoutCancel(ctx))
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to run scheduled transfers", "error", err)
+		}
+
+		s.mu.Lock()
+		s.lastPoll, s.lastPollErr = time.Now(), err
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Health reports when Run last finished polling, how often it is meant to poll and the error
+// of the last poll. A zero pollInterval means Run has not started.
+func (s *ScheduleService) Health() (lastPoll time.Time, pollInterval time.Duration, lastErr error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastPoll, s.pollInterval, s.lastPollErr
+}
+
+type scheduledRun struct {
+	transfer model.ScheduledTransfer
+	runID    int
+}
+
+// RunDue executes the transfers that are due and returns how many were attempted.
+// Each occurrence is claimed and the schedule advanced in one committed transaction
+// before the coins move, so an occurrence runs at most once across all replicas;
+// if the process dies after the claim, the occurrence stays "running" and is not retried.
+func (s *ScheduleService) RunDue(ctx context.Context) (int, error) {
+	runs, err := s.claimDue(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	for _, run := range runs {
+		st := run.transfer
+		status, runErr := model.RunStatusSucceeded, ""
+		err := s.walletService.Transfer(ctx, model.Transaction{
+			SenderID:   st.SenderID,
+			ReceiverID: st.ReceiverID,
+			Amount:     st.Amount,
+			Memo:       st.Memo,
+			Category:   st.Category,
+		})
+		if err != nil {
+			status, runErr = model.RunStatusFailed, err.Error()
+			s.logger.WarnContext(ctx, "scheduled transfer failed", "scheduled_transfer_id", st.ID, "error", err)
+		}
+
+		if err := s.scheduleRepo.FinishRun(ctx, run.runID, status, runErr); err != nil {
+			s.logger.ErrorContext(ctx, "failed to record run of scheduled transfer",
+				"scheduled_transfer_id", st.ID, "run_id", run.runID, "error", err)
+		}
+	}
+	return len(runs), nil
+}
+
+func (s *ScheduleService) claimDue(ctx context.Context, now time.Time) ([]scheduledRun, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil || err != nil {
+			rollback(ctx, s.logger, tx, "ScheduleService.claimDue", err, p)
+		}
+	}()
+
+	scheduleRepoTx := repository.NewScheduledTransferRepositoryWithTx(tx)
+
+	due, err := scheduleRepoTx.ClaimDue(ctx, now, scheduleClaimLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []scheduledRun{}
+	for _, st := range due {
+		var runID int
+		var started bool
+		runID, started, err = scheduleRepoTx.StartRun(ctx, st.ID, st.NextRunAt)
+		if err != nil {
+			return nil, err
+		}
+
+		next, status := nextOccurrence(st, now)
+		if err = scheduleRepoTx.Advance(ctx, st.ID, st.NextRunAt, next, status); err != nil {
+			return nil, err
+		}
+
+		if started {
+			runs = append(runs, scheduledRun{transfer: st, runID: runID})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return runs, nil
+}
+
+// nextOccurrence returns the first occurrence after now, skipping the ones that were missed.
+// A one-off transfer keeps its time and is completed.
+func nextOccurrence(st model.ScheduledTransfer, now time.Time) (time.Time, string) {
+	if st.IntervalSeconds == 0 {
+		return st.NextRunAt, model.ScheduleStatusCompleted
+	}
+
+	interval := time.Duration(st.IntervalSeconds) * time.Second
+	next := st.NextRunAt.Add(interval)
+	if !next.After(now) {
+		next = next.Add((now.Sub(next)/interval + 1) * interval)
+	}
+	return next, model.ScheduleStatusActive
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+func TestNextOccurrence(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		st         model.ScheduledTransfer
+		wantNext   time.Time
+		wantStatus string
+	}{
+		{
+			name:       "one-off transfer is completed",
+			st:         model.ScheduledTransfer{NextRunAt: now.Add(-time.Minute)},
+			wantNext:   now.Add(-time.Minute),
+			wantStatus: model.ScheduleStatusCompleted,
+		},
+		{
+			name:       "recurring transfer moves by one interval",
+			st:         model.ScheduledTransfer{IntervalSeconds: 3600, NextRunAt: now.Add(-time.Minute)},
+			wantNext:   now.Add(59 * time.Minute),
+			wantStatus: model.ScheduleStatusActive,
+		},
+		{
+			name:       "missed occurrences are skipped",
+			st:         model.ScheduledTransfer{IntervalSeconds: 3600, NextRunAt: now.Add(-150 * time.Minute)},
+			wantNext:   now.Add(30 * time.Minute),
+			wantStatus: model.ScheduleStatusActive,
+		},
+		{
+			name:       "occurrence exactly at now is skipped",
+			st:         model.ScheduledTransfer{IntervalSeconds: 3600, NextRunAt: now.Add(-time.Hour)},
+			wantNext:   now.Add(time.Hour),
+			wantStatus: model.ScheduleStatusActive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, status := nextOccurrence(tt.st, now)
+			if !next.Equal(tt.wantNext) || status != tt.wantStatus {
+				t.Errorf("nextOccurrence() = %v, %q, want %v, %q", next, status, tt.wantNext, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func newTestScheduleService(db *sql.DB) *ScheduleService {
+	userRepo := repository.NewUserRepository(db)
+	walletService := NewWalletService(userRepo, repository.NewTransactionRepository(db), db, time.Hour, discardLogger())
+	return NewScheduleService(repository.NewScheduledTransferRepository(db), userRepo, walletService, db, discardLogger())
+}
+
+// createScheduledTransfer inserts st directly, so it may already be due.
+func createScheduledTransfer(t *testing.T, db *sql.DB, st model.ScheduledTransfer) model.ScheduledTransfer {
+	t.Helper()
+	st.Category = model.CategoryOther
+	created, err := repository.NewScheduledTransferRepository(db).Create(context.Background(), st)
+	if err != nil {
+		t.Fatalf("failed to create scheduled transfer: %v", err)
+	}
+	return created
+}
+
+func TestRunDueClaimsAndAdvancesSchedules(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := newTestScheduleService(db)
+	sender, receiver := createTestUser(t, db, 1), createTestUser(t, db, 2)
+	now := time.Now()
+
+	recurring := createScheduledTransfer(t, db, model.ScheduledTransfer{SenderID: sender, ReceiverID: receiver, Amount: 10, IntervalSeconds: 3600, NextRunAt: now.Add(-150 * time.Minute)})
+	oneOff := createScheduledTransfer(t, db, model.ScheduledTransfer{SenderID: sender, ReceiverID: receiver, Amount: 20, NextRunAt: now.Add(-time.Minute)})
+	unaffordable := createScheduledTransfer(t, db, model.ScheduledTransfer{SenderID: sender, ReceiverID: receiver, Amount: 5000, NextRunAt: now.Add(-time.Minute)})
+	future := createScheduledTransfer(t, db, model.ScheduledTransfer{SenderID: sender, ReceiverID: receiver, Amount: 40, NextRunAt: now.Add(time.Hour)})
+	paused := createScheduledTransfer(t, db, model.ScheduledTransfer{SenderID: sender, ReceiverID: receiver, Amount: 80, IntervalSeconds: 3600, NextRunAt: now.Add(-time.Minute)})
+	if _, err := svc.Pause(ctx, paused.ID, sender); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+
+	attempted, err := svc.RunDue(ctx)
+	if err != nil {
+		t.Fatalf("RunDue() error = %v", err)
+	}
+	if attempted != 3 {
+		t.Errorf("RunDue() attempted %d transfers, want 3", attempted)
+	}
+	// Missed occurrences of the recurring transfer are skipped rather than caught up.
+	if got := userCoins(t, db, sender); got != 970 {
+		t.Errorf("sender has %d coins, want 970", got)
+	}
+
+	got, err := svc.scheduleRepo.GetBySender(ctx, recurring.ID, sender)
+	if err != nil {
+		t.Fatalf("GetBySender() error = %v", err)
+	}
+	if got.Status != model.ScheduleStatusActive || !got.NextRunAt.After(now) || got.NextRunAt.After(now.Add(time.Hour)) {
+		t.Errorf("recurring transfer after its run = %+v, want active and due within the next hour", got)
+	}
+	if got.LastRunAt == nil || !got.LastRunAt.Equal(recurring.NextRunAt) {
+		t.Errorf("recurring transfer last ran at %v, want %v", got.LastRunAt, recurring.NextRunAt)
+	}
+	for _, st := range []model.ScheduledTransfer{oneOff, unaffordable} {
+		if got, err := svc.scheduleRepo.GetBySender(ctx, st.ID, sender); err != nil || got.Status != model.ScheduleStatusCompleted {
+			t.Errorf("one-off transfer %d after its run = %+v, %v, want completed", st.ID, got, err)
+		}
+	}
+
+	runs, err := svc.ListRuns(ctx, unaffordable.ID, sender)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("ListRuns() = %v, %v, want one run", runs, err)
+	}
+	if runs[0].Status != model.RunStatusFailed || runs[0].Error == "" || runs[0].FinishedAt == nil {
+		t.Errorf("run of an unaffordable transfer = %+v, want a finished failure with its error", runs[0])
+	}
+	runs, err = svc.ListRuns(ctx, oneOff.ID, sender)
+	if err != nil || len(runs) != 1 || runs[0].Status != model.RunStatusSucceeded {
+		t.Errorf("ListRuns() = %+v, %v, want one succeeded run", runs, err)
+	}
+	for _, st := range []model.ScheduledTransfer{future, paused} {
+		if runs, err := svc.ListRuns(ctx, st.ID, sender); err != nil || len(runs) != 0 {
+			t.Errorf("ListRuns(%d) = %v, %v, want no runs", st.ID, runs, err)
+		}
+	}
+
+	if attempted, err := svc.RunDue(ctx); err != nil || attempted != 0 {
+		t.Errorf("second RunDue() = %d, %v, want nothing due", attempted, err)
+	}
+	checkBooks(t, db)
+}
+
+// TestRunDueRunsEachOccurrenceOnce polls from several replicas at once: each due transfer
+// must be claimed by exactly one of them.
+func TestRunDueRunsEachOccurrenceOnce(t *testing.T) {
+	db := openTestDB(t)
+	db.SetMaxOpenConns(16)
+	ctx := context.Background()
+	sender, receiver := createTestUser(t, db, 1), createTestUser(t, db, 2)
+	const transfers = 10
+	for i := 0; i < transfers; i++ {
+		createScheduledTransfer(t, db, model.ScheduledTransfer{SenderID: sender, ReceiverID: receiver, Amount: 10, NextRunAt: time.Now().Add(-time.Minute)})
+	}
+
+	const replicas = 4
+	var wg sync.WaitGroup
+	attempted := make(chan int, replicas)
+	for i := 0; i < replicas; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := newTestScheduleService(db).RunDue(ctx)
+			if err != nil {
+				t.Errorf("RunDue() error = %v", err)
+			}
+			attempted <- n
+		}()
+	}
+	wg.Wait()
+	close(attempted)
+
+	total := 0
+	for n := range attempted {
+		total += n
+	}
+	if total != transfers {
+		t.Errorf("replicas attempted %d transfers, want %d", total, transfers)
+	}
+	if got := userCoins(t, db, receiver); got != 1000+10*transfers {
+		t.Errorf("receiver has %d coins, want %d", got, 1000+10*transfers)
+	}
+	checkBooks(t, db)
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+var (
+	ErrInvalidLimits          = errors.New("invalid transfer limits")
+	ErrInvalidLimitScope      = errors.New("invalid transfer limit scope")
+	ErrTransferLimitsNotFound = errors.New("transfer limits not found")
+)
+
+// LimitExceededError reports which transfer limit a transfer would break.
+type LimitExceededError struct {
+	Limit string
+	Max   int
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("transfer limit %s of %d exceeded", e.Limit, e.Max)
+}
+
+type TransferLimitService struct {
+	limitRepo *repository.TransferLimitRepository
+	userRepo  *repository.UserRepository
+}
+
+func NewTransferLimitService(limitRepo *repository.TransferLimitRepository, userRepo *repository.UserRepository) *TransferLimitService {
+	return &TransferLimitService{limitRepo: limitRepo, userRepo: userRepo}
+}
+
+func (s *TransferLimitService) List(ctx context.Context) ([]model.TransferLimits, error) {
+	limits, err := s.limitRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transfer limits: %w", err)
+	}
+	return limits, nil
+}
+
+// Update replaces the limits of limits.Scope. A nil limit is inherited from the global
+// limits (or is no limit in the global scope) and model.LimitUnlimited is no limit.
+func (s *TransferLimitService) Update(ctx context.Context, limits model.TransferLimits) (model.TransferLimits, error) {
+	if !model.IsValidLimitScope(limits.Scope) {
+		return model.TransferLimits{}, ErrInvalidLimitScope
+	}
+	if err := validateLimits(limits); err != nil {
+		return model.TransferLimits{}, err
+	}
+
+	saved, err := s.limitRepo.Save(ctx, limits)
+	if err != nil {
+		return model.TransferLimits{}, fmt.Errorf("failed to update transfer limits: %w", err)
+	}
+	return saved, nil
+}
+
+// UpdateForUser replaces the limits of the user limits.UserID, which override those of
+// their role. A nil limit is inherited from the role and model.LimitUnlimited is no limit.
+func (s *TransferLimitService) UpdateForUser(ctx context.Context, limits model.TransferLimits) (model.TransferLimits, error) {
+	if err := validateLimits(limits); err != nil {
+		return model.TransferLimits{}, err
+	}
+	if _, err := s.userRepo.GetCoins(ctx, limits.UserID); errors.Is(err, repository.ErrUserNotFound) {
+		return model.TransferLimits{}, ErrUserNotFound
+	} else if err != nil {
+		return model.TransferLimits{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	saved, err := s.limitRepo.SaveForUser(ctx, limits)
+	if err != nil {
+		return model.TransferLimits{}, fmt.Errorf("failed to update transfer limits: %w", err)
+	}
+	return saved, nil
+}
+
+// DeleteForUser removes the limits of userID, so only those of their role apply.
+func (s *TransferLimitService) DeleteForUser(ctx context.Context, userID int) error {
+	err := s.limitRepo.DeleteForUser(ctx, userID)
+	if errors.Is(err, repository.ErrTransferLimitsNotFound) {
+		return ErrTransferLimitsNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to delete transfer limits: %w", err)
+	}
+	return nil
+}
+
+func validateLimits(limits model.TransferLimits) error {
+	for _, value := range []*int{limits.MaxPerTransfer, limits.MaxDailyAmount, limits.MaxWeeklyAmount, limits.MaxDailyCount} {
+		if value != nil && *value < 0 {
+			return ErrInvalidLimits
+		}
+	}
+	return nil
+}
+
+// checkTransferLimits returns a LimitExceededError if sending amounts would break the
+// limits that apply to the sender. The sender row must already be locked in tx, so
+// concurrent transfers of the same sender are counted one after another.
+func checkTransferLimits(ctx context.Context, tx *sql.Tx, sender *model.User, amounts ...int) error {
+	limits, err := repository.NewTransferLimitRepositoryWithTx(tx).GetEffective(ctx, sender.ID, sender.Role)
+	if err != nil {
+		return err
+	}
+	if limits.MaxPerTransfer == nil && limits.MaxDailyAmount == nil && limits.MaxWeeklyAmount == nil && limits.MaxDailyCount == nil {
+		return nil
+	}
+
+	total := 0
+	for _, amount := range amounts {
+		if limits.MaxPerTransfer != nil && amount > *limits.MaxPerTransfer {
+			return &LimitExceededError{Limit: model.LimitMaxPerTransfer, Max: *limits.MaxPerTransfer}
+		}
+		total += amount
+	}
+
+	now := time.Now()
+	totals, err := repository.NewTransactionRepositoryWithTx(tx).GetOutgoingTotals(ctx, sender.ID,
+		now.Add(-24*time.Hour), now.Add(-7*24*time.Hour))
+	if err != nil {
+		return err
+	}
+
+	if limits.MaxDailyAmount != nil && totals.DailyAmount+total > *limits.MaxDailyAmount {
+		return &LimitExceededError{Limit: model.LimitMaxDailyAmount, Max: *limits.MaxDailyAmount}
+	}
+	if limits.MaxWeeklyAmount != nil && totals.WeeklyAmount+total > *limits.MaxWeeklyAmount {
+		return &LimitExceededError{Limit: model.LimitMaxWeeklyAmount, Max: *limits.MaxWeeklyAmount}
+	}
+	if limits.MaxDailyCount != nil && totals.DailyCount+len(amounts) > *limits.MaxDailyCount {
+		return &LimitExceededError{Limit: model.LimitMaxDailyCount, Max: *limits.MaxDailyCount}
+	}
+	return nil
+}
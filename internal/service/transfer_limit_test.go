@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+func TestValidateLimits(t *testing.T) {
+	n := func(v int) *int { return &v }
+	tests := []struct {
+		name    string
+		limits  model.TransferLimits
+		wantErr error
+	}{
+		{"none", model.TransferLimits{}, nil},
+		{"positive", model.TransferLimits{MaxPerTransfer: n(100), MaxDailyCount: n(3)}, nil},
+		{"unlimited", model.TransferLimits{MaxDailyAmount: n(model.LimitUnlimited)}, nil},
+		{"negative", model.TransferLimits{MaxWeeklyAmount: n(-1)}, ErrInvalidLimits},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateLimits(tt.limits); err != tt.wantErr {
+				t.Errorf("validateLimits() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTransferLimitsResolveUserThenRoleThenGlobal(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	userRepo := repository.NewUserRepository(db)
+	limitService := NewTransferLimitService(repository.NewTransferLimitRepository(db), userRepo)
+	walletService := NewWalletService(userRepo, repository.NewTransactionRepository(db), db, time.Hour, discardLogger())
+	alice, bob := createTestUser(t, db, 1), createTestUser(t, db, 2)
+	n := func(v int) *int { return &v }
+
+	transfer := func(amount int) error {
+		return walletService.Transfer(ctx, model.Transaction{SenderID: alice, ReceiverID: bob, Amount: amount})
+	}
+	wantLimit := func(err error, max int) {
+		t.Helper()
+		var limitErr *LimitExceededError
+		if !errors.As(err, &limitErr) || limitErr.Limit != model.LimitMaxPerTransfer || limitErr.Max != max {
+			t.Errorf("Transfer() error = %v, want %s of %d exceeded", err, model.LimitMaxPerTransfer, max)
+		}
+	}
+
+	if _, err := limitService.Update(ctx, model.TransferLimits{Scope: model.LimitScopeGlobal, MaxPerTransfer: n(100)}); err != nil {
+		t.Fatalf("Update(global) error = %v", err)
+	}
+	wantLimit(transfer(101), 100)
+
+	if _, err := limitService.Update(ctx, model.TransferLimits{Scope: model.RoleUser, MaxPerTransfer: n(model.LimitUnlimited)}); err != nil {
+		t.Fatalf("Update(user role) error = %v", err)
+	}
+	if err := transfer(300); err != nil {
+		t.Errorf("Transfer() with the global limit lifted by the role error = %v", err)
+	}
+
+	if _, err := limitService.UpdateForUser(ctx, model.TransferLimits{UserID: alice, MaxPerTransfer: n(50)}); err != nil {
+		t.Fatalf("UpdateForUser() error = %v", err)
+	}
+	wantLimit(transfer(51), 50)
+	if err := transfer(50); err != nil {
+		t.Errorf("Transfer() within the user limit error = %v", err)
+	}
+
+	if err := limitService.DeleteForUser(ctx, alice); err != nil {
+		t.Fatalf("DeleteForUser() error = %v", err)
+	}
+	if err := transfer(300); err != nil {
+		t.Errorf("Transfer() after deleting the user limit error = %v", err)
+	}
+	if err := limitService.DeleteForUser(ctx, alice); err != ErrTransferLimitsNotFound {
+		t.Errorf("second DeleteForUser() error = %v, want %v", err, ErrTransferLimitsNotFound)
+	}
+	if _, err := limitService.UpdateForUser(ctx, model.TransferLimits{UserID: 99, MaxPerTransfer: n(50)}); err != ErrUserNotFound {
+		t.Errorf("UpdateForUser() of a missing user error = %v, want %v", err, ErrUserNotFound)
+	}
+
+	limits, err := limitService.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(limits) != 2 {
+		t.Errorf("List() = %+v, want the global and user role limits", limits)
+	}
+}
@@ -5,133 +5,599 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/metrics"
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
 	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/tracing"
 )
 
 var (
 	ErrInvalidAmount     = errors.New("invalid amount")
 	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrInvalidMemo       = errors.New("invalid memo")
+	ErrInvalidCategory   = errors.New("invalid category")
+	ErrSelfTransfer      = errors.New("cannot transfer coins to yourself")
+	ErrInvalidBatch      = errors.New("invalid batch size")
+	ErrBatchRejected     = errors.New("batch contains invalid transfers")
+
+	ErrTransactionNotFound   = errors.New("transaction not found")
+	ErrAlreadyReversed       = errors.New("transaction already reversed")
+	ErrNotReversible         = errors.New("transaction cannot be reversed")
+	ErrReversalWindowExpired = errors.New("reversal window expired")
 )
 
+// MaxBatchSize limits the number of transfers in one batch.
+const MaxBatchSize = 100
+
 type WalletService struct {
 	userRepo        *repository.UserRepository
 	transactionRepo *repository.TransactionRepository
 	db              *sql.DB
+	// reversalWindow is how long a sender may cancel their own transfer.
+	reversalWindow time.Duration
+	logger         *slog.Logger
 }
 
-func NewWalletService(userRepo *repository.UserRepository, transactionRepo *repository.TransactionRepository, db *sql.DB) *WalletService {
+func NewWalletService(userRepo *repository.UserRepository, transactionRepo *repository.TransactionRepository, db *sql.DB, reversalWindow time.Duration, logger *slog.Logger) *WalletService {
 	return &WalletService{
 		userRepo:        userRepo,
 		transactionRepo: transactionRepo,
 		db:              db,
+		reversalWindow:  reversalWindow,
+		logger:          logger,
+	}
+}
+
+func (s *WalletService) Transfer(ctx context.Context, t model.Transaction) (err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.Transfer",
+		tracing.AttrUserID.Int(t.SenderID), tracing.AttrReceiverID.Int(t.ReceiverID), tracing.AttrAmount.Int(t.Amount))
+	defer func() { tracing.End(span, err) }()
+
+	_, err = s.TransferIdempotent(ctx, nil, t)
+	return err
+}
+
+// TransferIdempotent performs the transfer and stores idem in the same transaction.
+// If idem.Key was already used for the same request, the stored record is returned and no coins move.
+func (s *WalletService) TransferIdempotent(ctx context.Context, idem *model.IdempotencyRecord, t model.Transaction) (_ *model.IdempotencyRecord, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.TransferIdempotent",
+		tracing.AttrUserID.Int(t.SenderID), tracing.AttrReceiverID.Int(t.ReceiverID), tracing.AttrAmount.Int(t.Amount))
+	defer func() { tracing.End(span, err) }()
+
+	if err := prepareTransfer(&t); err != nil {
+		return nil, err
+	}
+
+	var record *model.IdempotencyRecord
+	err = runWithRetry(ctx, func() error {
+		var err error
+		record, err = s.transfer(ctx, idem, t)
+		return err
+	})
+	if errors.Is(err, ErrInsufficientFunds) {
+		metrics.InsufficientFunds(metrics.OperationTransfer)
 	}
+	return record, err
 }
 
-func (s *WalletService) Transfer(ctx context.Context, senderID, receiverID int, amount int) error {
-	if amount <= 0 {
+// prepareTransfer validates the amount and category and sanitizes the memo in place.
+func prepareTransfer(t *model.Transaction) error {
+	if t.Amount <= 0 {
 		return ErrInvalidAmount
 	}
+	if t.SenderID == t.ReceiverID {
+		return ErrSelfTransfer
+	}
+
+	if t.Category == "" {
+		t.Category = model.CategoryOther
+	}
+	if !model.IsValidCategory(t.Category) {
+		return ErrInvalidCategory
+	}
+
+	memo, err := sanitizeMemo(t.Memo)
+	if err != nil {
+		return err
+	}
+	t.Memo = memo
+	return nil
+}
+
+// sanitizeMemo drops invalid UTF-8 and control characters and collapses whitespace,
+// so a memo is always a single line of printable text.
+func sanitizeMemo(memo string) (string, error) {
+	memo = strings.ToValidUTF8(memo, "")
+	memo = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, memo)
+	memo = strings.Join(strings.Fields(memo), " ")
+
+	if utf8.RuneCountInString(memo) > model.MaxMemoLength {
+		return "", ErrInvalidMemo
+	}
+	return memo, nil
+}
 
+func (s *WalletService) transfer(ctx context.Context, idem *model.IdempotencyRecord, t model.Transaction) (*model.IdempotencyRecord, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer func() {
 		if p := recover(); p != nil || err != nil {
-			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("failed to rollback transaction: %v", rbErr)
-			}
+			rollback(ctx, s.logger, tx, "WalletService.transfer", err, p)
 		}
 	}()
 
+	if idem != nil {
+		var stored *model.IdempotencyRecord
+		stored, err = claimIdempotencyKey(ctx, tx, idem)
+		if err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			rollback(ctx, s.logger, tx, "WalletService.transfer", nil, nil)
+			return stored, nil
+		}
+	}
+
+	if _, err = executeTransfer(ctx, tx, t); err != nil {
+		return nil, err
+	}
+
+	// Сохраняем ответ для повторных запросов с тем же ключом
+	if idem != nil {
+		if err = storeIdempotentResponse(ctx, tx, idem); err != nil {
+			return nil, fmt.Errorf("failed to store idempotency key: %w", err)
+		}
+	}
+
+	// Коммитим транзакцию
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	metrics.TransferCompleted(t.Amount)
+	return idem, nil
+}
+
+// executeTransfer moves the coins of a prepared transfer inside tx and returns the ID of the recorded transaction.
+func executeTransfer(ctx context.Context, tx *sql.Tx, t model.Transaction) (int, error) {
 	// Используем репозитории с поддержкой транзакций
 	userRepoTx := repository.NewUserRepositoryWithTx(tx)
 	transactionRepoTx := repository.NewTransactionRepositoryWithTx(tx)
 
-	// Получаем отправителя и проверяем его баланс
-	sender, err := userRepoTx.GetByID(ctx, senderID)
+	// Блокируем обоих пользователей в порядке возрастания ID, чтобы избежать взаимных блокировок
+	users, err := userRepoTx.LockUsers(ctx, t.SenderID, t.ReceiverID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return 0, ErrUserNotFound
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to lock users: %w", err)
+	}
+
+	available, err := availableCoins(ctx, userRepoTx, t.SenderID)
 	if err != nil {
-		return fmt.Errorf("failed to get sender user: %w", err)
+		return 0, err
+	}
+	if available < t.Amount {
+		return 0, ErrInsufficientFunds
+	}
+
+	// Лимиты проверяются под блокировкой отправителя, чтобы параллельные переводы учитывались
+	if err := checkTransferLimits(ctx, tx, users[t.SenderID], t.Amount); err != nil {
+		return 0, err
 	}
 
-	if sender.Coins < amount {
-		return ErrInsufficientFunds
+	// Проводим перевод по журналу, балансы обновляются вместе с проводками
+	entryID, err := postTransfer(ctx, tx, t.SenderID, t.ReceiverID, t.Amount)
+	if errors.Is(err, repository.ErrNegativeBalance) {
+		return 0, ErrInsufficientFunds
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to post transfer: %w", err)
 	}
 
-	// Получаем получателя
-	receiver, err := userRepoTx.GetByID(ctx, receiverID)
+	// Записываем транзакцию
+	id, err := transactionRepoTx.Create(ctx, t, entryID)
 	if err != nil {
-		return fmt.Errorf("failed to get receiver user: %w", err)
+		return 0, fmt.Errorf("failed to record transaction: %w", err)
 	}
+	return id, nil
+}
 
-	// Обновляем балансы
-	senderNewBalance := sender.Coins - amount
-	receiverNewBalance := receiver.Coins + amount
+// availableCoins returns the part of the locked user's balance that is not reserved by holds.
+func availableCoins(ctx context.Context, userRepo *repository.UserRepository, userID int) (int, error) {
+	coins, held, err := userRepo.GetBalance(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get balance: %w", err)
+	}
+	return coins - held, nil
+}
 
-	if err := userRepoTx.UpdateCoins(ctx, senderID, senderNewBalance); err != nil {
-		return fmt.Errorf("failed to update sender coins: %w", err)
+// TransferBatch applies all transfers from senderID in one transaction, or none of them.
+// If some transfers are invalid it returns ErrBatchRejected with an error per transfer,
+// nil for the valid ones.
+func (s *WalletService) TransferBatch(ctx context.Context, idem *model.IdempotencyRecord, senderID int, transfers []model.Transaction) (_ *model.IdempotencyRecord, _ []error, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.TransferBatch",
+		tracing.AttrUserID.Int(senderID), tracing.AttrBatchSize.Int(len(transfers)))
+	defer func() { tracing.End(span, err) }()
+
+	if len(transfers) == 0 || len(transfers) > MaxBatchSize {
+		return nil, nil, ErrInvalidBatch
 	}
 
-	if err := userRepoTx.UpdateCoins(ctx, receiverID, receiverNewBalance); err != nil {
-		return fmt.Errorf("failed to update receiver coins: %w", err)
+	itemErrs := make([]error, len(transfers))
+	rejected := false
+	for i := range transfers {
+		transfers[i].SenderID = senderID
+		if err := prepareTransfer(&transfers[i]); err != nil {
+			itemErrs[i] = err
+			rejected = true
+		}
+	}
+	if rejected {
+		return nil, itemErrs, ErrBatchRejected
 	}
 
-	// Записываем транзакцию
-	if err := transactionRepoTx.Create(ctx, senderID, receiverID, amount); err != nil {
-		return fmt.Errorf("failed to record transaction: %w", err)
+	var record *model.IdempotencyRecord
+	err = runWithRetry(ctx, func() error {
+		var err error
+		record, itemErrs, err = s.transferBatch(ctx, idem, senderID, transfers)
+		return err
+	})
+	if errors.Is(err, ErrInsufficientFunds) {
+		metrics.InsufficientFunds(metrics.OperationTransfer)
+	}
+	return record, itemErrs, err
+}
+
+func (s *WalletService) transferBatch(ctx context.Context, idem *model.IdempotencyRecord, senderID int, transfers []model.Transaction) (*model.IdempotencyRecord, []error, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil || err != nil {
+			rollback(ctx, s.logger, tx, "WalletService.transferBatch", err, p)
+		}
+	}()
+
+	if idem != nil {
+		var stored *model.IdempotencyRecord
+		stored, err = claimIdempotencyKey(ctx, tx, idem)
+		if err != nil {
+			return nil, nil, err
+		}
+		if stored != nil {
+			rollback(ctx, s.logger, tx, "WalletService.transferBatch", nil, nil)
+			return stored, nil, nil
+		}
+	}
+
+	userRepoTx := repository.NewUserRepositoryWithTx(tx)
+	transactionRepoTx := repository.NewTransactionRepositoryWithTx(tx)
+
+	// Блокируем отправителя и всех получателей одним проходом в порядке возрастания ID
+	ids := []int{senderID}
+	total := 0
+	for _, t := range transfers {
+		ids = append(ids, t.ReceiverID)
+		total += t.Amount
+	}
+	users, err := userRepoTx.LockExistingUsers(ctx, ids...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to lock users: %w", err)
+	}
+	if _, ok := users[senderID]; !ok {
+		err = ErrUserNotFound
+		return nil, nil, err
+	}
+
+	itemErrs := make([]error, len(transfers))
+	rejected := false
+	for i, t := range transfers {
+		if _, ok := users[t.ReceiverID]; !ok {
+			itemErrs[i] = ErrUserNotFound
+			rejected = true
+		}
+	}
+	if rejected {
+		err = ErrBatchRejected
+		return nil, itemErrs, err
+	}
+
+	// Баланс проверяется один раз на всю сумму пакета
+	available, err := availableCoins(ctx, userRepoTx, senderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if available < total {
+		err = ErrInsufficientFunds
+		return nil, nil, err
+	}
+
+	amounts := make([]int, len(transfers))
+	for i, t := range transfers {
+		amounts[i] = t.Amount
+	}
+	if err = checkTransferLimits(ctx, tx, users[senderID], amounts...); err != nil {
+		return nil, nil, err
+	}
+
+	entryID, err := postBatchTransfer(ctx, tx, senderID, transfers)
+	if errors.Is(err, repository.ErrNegativeBalance) {
+		err = ErrInsufficientFunds
+		return nil, nil, err
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to post batch transfer: %w", err)
+	}
+
+	for _, t := range transfers {
+		if _, err = transactionRepoTx.Create(ctx, t, entryID); err != nil {
+			return nil, nil, fmt.Errorf("failed to record transaction: %w", err)
+		}
+	}
+
+	if idem != nil {
+		if err = storeIdempotentResponse(ctx, tx, idem); err != nil {
+			return nil, nil, fmt.Errorf("failed to store idempotency key: %w", err)
+		}
 	}
 
-	// Коммитим транзакцию
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return nil
+	for _, t := range transfers {
+		metrics.TransferCompleted(t.Amount)
+	}
+	return idem, nil, nil
+}
+
+// CancelTransfer lets the sender take back their transfer within the reversal window.
+func (s *WalletService) CancelTransfer(ctx context.Context, senderID int, transactionID int) (_ *model.Transaction, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.CancelTransfer",
+		tracing.AttrUserID.Int(senderID), tracing.AttrTransactionID.Int(transactionID))
+	defer func() { tracing.End(span, err) }()
+
+	var reversal *model.Transaction
+	err = runWithRetry(ctx, func() error {
+		var err error
+		reversal, err = s.reverseTransfer(ctx, transactionID, senderID)
+		return err
+	})
+	return reversal, err
+}
+
+// ReverseTransfer reverses any transfer on behalf of an admin, regardless of the window.
+func (s *WalletService) ReverseTransfer(ctx context.Context, transactionID int) (_ *model.Transaction, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.ReverseTransfer", tracing.AttrTransactionID.Int(transactionID))
+	defer func() { tracing.End(span, err) }()
+
+	var reversal *model.Transaction
+	err = runWithRetry(ctx, func() error {
+		var err error
+		reversal, err = s.reverseTransfer(ctx, transactionID, 0)
+		return err
+	})
+	return reversal, err
 }
 
-func (s *WalletService) GetWallet(ctx context.Context, userID int) (*model.Wallet, error) {
-	coins, err := s.userRepo.GetCoins(ctx, userID)
+// reverseTransfer records a compensating transfer linked to the original one.
+// If senderID is not 0, only that sender may reverse it and only within the window.
+func (s *WalletService) reverseTransfer(ctx context.Context, transactionID int, senderID int) (*model.Transaction, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get coins for user %d: %w", userID, err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer func() {
+		if p := recover(); p != nil || err != nil {
+			rollback(ctx, s.logger, tx, "WalletService.reverseTransfer", err, p)
+		}
+	}()
 
-	history, err := s.GetWalletHistory(ctx, userID)
+	userRepoTx := repository.NewUserRepositoryWithTx(tx)
+	transactionRepoTx := repository.NewTransactionRepositoryWithTx(tx)
+
+	original, err := transactionRepoTx.GetByIDForUpdate(ctx, transactionID)
+	if errors.Is(err, repository.ErrTransactionNotFound) {
+		err = ErrTransactionNotFound
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+
+	// Чужие переводы отправитель не видит, поэтому для него они не существуют
+	if senderID != 0 && original.SenderID != senderID {
+		err = ErrTransactionNotFound
+		return nil, err
+	}
+	if original.ReversesID != 0 {
+		err = ErrNotReversible
+		return nil, err
+	}
+	if original.ReversedByID != 0 {
+		err = ErrAlreadyReversed
+		return nil, err
+	}
+	if senderID != 0 && time.Since(original.CreatedAt) > s.reversalWindow {
+		err = ErrReversalWindowExpired
+		return nil, err
+	}
+
+	if _, err = userRepoTx.LockUsers(ctx, original.SenderID, original.ReceiverID); err != nil {
+		return nil, fmt.Errorf("failed to lock users: %w", err)
+	}
+	available, err := availableCoins(ctx, userRepoTx, original.ReceiverID)
+	if err != nil {
+		return nil, err
+	}
+	if available < original.Amount {
+		err = ErrInsufficientFunds
+		return nil, err
+	}
+
+	entryID, err := postReversal(ctx, tx, original)
+	if errors.Is(err, repository.ErrNegativeBalance) {
+		err = ErrInsufficientFunds
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to post reversal: %w", err)
+	}
+
+	reversal := model.Transaction{
+		SenderID:   original.ReceiverID,
+		ReceiverID: original.SenderID,
+		Amount:     original.Amount,
+		Memo:       fmt.Sprintf("Reversal of transaction %d", original.ID),
+		Category:   original.Category,
+		ReversesID: original.ID,
+	}
+	reversal.ID, err = transactionRepoTx.Create(ctx, reversal, entryID)
+	if errors.Is(err, repository.ErrTransactionAlreadyReversed) {
+		err = ErrAlreadyReversed
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to record reversal: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "transaction reversed", "transaction_id", original.ID, "reversal_id", reversal.ID)
+	return &reversal, nil
+}
+
+// AdjustBalance adds delta (which may be negative) to the user's balance on behalf of an admin.
+func (s *WalletService) AdjustBalance(ctx context.Context, userID int, delta int) (_ *model.User, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.AdjustBalance", tracing.AttrUserID.Int(userID), tracing.AttrAmount.Int(delta))
+	defer func() { tracing.End(span, err) }()
+
+	if delta == 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	var user *model.User
+	err = runWithRetry(ctx, func() error {
+		var err error
+		user, err = s.adjustBalance(ctx, userID, delta)
+		return err
+	})
+	return user, err
+}
+
+func (s *WalletService) adjustBalance(ctx context.Context, userID int, delta int) (*model.User, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil || err != nil {
+			rollback(ctx, s.logger, tx, "WalletService.adjustBalance", err, p)
+		}
+	}()
+
+	userRepoTx := repository.NewUserRepositoryWithTx(tx)
+	user, err := userRepoTx.GetByIDForUpdate(ctx, userID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		err = ErrUserNotFound
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.Coins+delta < 0 {
+		err = ErrInsufficientFunds
+		return nil, err
+	}
+
+	if _, err = postAdjustment(ctx, tx, userID, delta); errors.Is(err, repository.ErrNegativeBalance) {
+		err = ErrInsufficientFunds
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to post adjustment: %w", err)
+	}
+	user.Coins += delta
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "balance adjusted", "account_user_id", userID, "delta", delta)
+	return user, nil
+}
+
+// GetWallet returns the total, held and available balance with the first page of the history.
+func (s *WalletService) GetWallet(ctx context.Context, userID int) (_ *model.Wallet, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.GetWallet", tracing.AttrUserID.Int(userID))
+	defer func() { tracing.End(span, err) }()
+
+	coins, held, err := s.userRepo.GetBalance(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance for user %d: %w", userID, err)
+	}
+
+	history, nextCursor, err := s.GetWalletHistory(ctx, userID, model.HistoryFilter{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get wallet history for user %d: %w", userID, err)
 	}
 
 	return &model.Wallet{
 		Coins:              coins,
+		HeldCoins:          held,
+		AvailableCoins:     coins - held,
 		TransactionHistory: history,
+		NextCursor:         nextCursor,
 	}, nil
 }
 
-func (s *WalletService) GetWalletHistory(ctx context.Context, userID int) ([]model.WalletHistoryEntry, error) {
-	transactions, err := s.transactionRepo.GetTransactionsByUserID(ctx, userID)
+// GetWalletHistory returns one page of the history and the cursor of the next page,
+// which is empty on the last page.
+func (s *WalletService) GetWalletHistory(ctx context.Context, userID int, filter model.HistoryFilter) (_ []model.WalletHistoryEntry, _ string, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.GetWalletHistory", tracing.AttrUserID.Int(userID))
+	defer func() { tracing.End(span, err) }()
+
+	filter.Page = normalizePage(filter.Page)
+
+	transactions, next, err := s.transactionRepo.GetTransactionsByUserID(ctx, userID, filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get transactions for user %d: %w", userID, err)
+		return nil, "", fmt.Errorf("failed to get transactions for user %d: %w", userID, err)
 	}
 
-	var historyEntries []model.WalletHistoryEntry
+	historyEntries := []model.WalletHistoryEntry{}
 	for _, tx := range transactions {
 		entry := model.WalletHistoryEntry{
+			TransactionID:   tx.ID,
 			Amount:          tx.Amount,
+			Memo:            tx.Memo,
+			Category:        tx.Category,
+			ReversesID:      tx.ReversesID,
+			ReversedByID:    tx.ReversedByID,
 			CreatedAt:       tx.CreatedAt.Format(time.RFC3339),
 			CounterpartyID:  tx.SenderID,
-			TransactionType: "incoming",
+			TransactionType: model.DirectionIncoming,
 		}
 
 		if tx.SenderID == userID {
-			entry.TransactionType = "outgoing"
+			entry.TransactionType = model.DirectionOutgoing
 			entry.CounterpartyID = tx.ReceiverID
 		}
 
 		historyEntries = append(historyEntries, entry)
 	}
 
-	return historyEntries, nil
-}
\ No newline at end of file
+	return historyEntries, EncodeCursor(next), nil
+}
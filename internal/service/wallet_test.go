@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/model"
+	"github.com/BAPBAP1/avito-tech-internship-winter-2025/internal/repository"
+)
+
+// TestConcurrentTransfersAndPurchases runs crossing transfers (A to B while B pays A) and
+// purchases against the same wallets at once. Every operation must either succeed or fail
+// with ErrInsufficientFunds: a deadlock or serialization failure escaping runWithRetry fails the test.
+func TestConcurrentTransfersAndPurchases(t *testing.T) {
+	db := openTestDB(t)
+	db.SetMaxOpenConns(16)
+	ctx := context.Background()
+	logger := discardLogger()
+	userRepo := repository.NewUserRepository(db)
+	walletService := NewWalletService(userRepo, repository.NewTransactionRepository(db), db, time.Hour, logger)
+	merchService := NewMerchService(repository.NewMerchRepository(db), userRepo, db, logger)
+
+	ids := []int{1, 2, 3, 4}
+	for _, id := range ids {
+		createTestUser(t, db, id)
+	}
+	const workers = 8
+	const rounds = 25
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*rounds*3)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				sender := ids[(w+r)%len(ids)]
+				receiver := ids[(w+r+1+w%2*2)%len(ids)]
+				amount := 37 + (w*rounds+r)%90
+
+				err := walletService.Transfer(ctx, model.Transaction{SenderID: sender, ReceiverID: receiver, Amount: amount})
+				if err != nil && !errors.Is(err, ErrInsufficientFunds) {
+					errs <- fmt.Errorf("transfer %d -> %d: %w", sender, receiver, err)
+				}
+
+				_, _, err = walletService.TransferBatch(ctx, nil, receiver, []model.Transaction{
+					{ReceiverID: sender, Amount: amount / 2},
+					{ReceiverID: ids[(w+r+2)%len(ids)], Amount: amount / 3},
+				})
+				if err != nil && !errors.Is(err, ErrInsufficientFunds) {
+					errs <- fmt.Errorf("batch from %d: %w", receiver, err)
+				}
+
+				err = merchService.PurchaseMerch(ctx, sender, []string{"pen", "cup", "book"}[r%3], "", "")
+				if err != nil && !errors.Is(err, ErrInsufficientFunds) {
+					errs <- fmt.Errorf("purchase by %d: %w", sender, err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	total := 0
+	for _, id := range ids {
+		coins := userCoins(t, db, id)
+		if coins < 0 {
+			t.Errorf("user %d has %d coins", id, coins)
+		}
+		total += coins
+	}
+	report := checkBooks(t, db)
+	for _, account := range report.SystemAccounts {
+		if account.Code == model.AccountMerchRevenue {
+			total += account.Balance
+		}
+	}
+	if want := 1000 * len(ids); total != want {
+		t.Errorf("users and merch revenue hold %d coins, want %d", total, want)
+	}
+}
+
+func TestTransferBatchIsAllOrNothing(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := NewWalletService(repository.NewUserRepository(db), repository.NewTransactionRepository(db), db, time.Hour, discardLogger())
+	sender, first, second := createTestUser(t, db, 1), createTestUser(t, db, 2), createTestUser(t, db, 3)
+
+	balances := func(want ...int) {
+		t.Helper()
+		for i, id := range []int{sender, first, second} {
+			if got := userCoins(t, db, id); got != want[i] {
+				t.Errorf("user %d has %d coins, want %d", id, got, want[i])
+			}
+		}
+	}
+
+	_, itemErrs, err := svc.TransferBatch(ctx, nil, sender, []model.Transaction{
+		{ReceiverID: first, Amount: 100},
+		{ReceiverID: 99, Amount: 100},
+		{ReceiverID: second, Amount: 100},
+	})
+	if !errors.Is(err, ErrBatchRejected) {
+		t.Fatalf("TransferBatch() with an unknown receiver error = %v, want %v", err, ErrBatchRejected)
+	}
+	if len(itemErrs) != 3 || itemErrs[0] != nil || !errors.Is(itemErrs[1], ErrUserNotFound) || itemErrs[2] != nil {
+		t.Errorf("TransferBatch() item errors = %v, want only the second transfer rejected", itemErrs)
+	}
+	balances(1000, 1000, 1000)
+
+	_, itemErrs, err = svc.TransferBatch(ctx, nil, sender, []model.Transaction{
+		{ReceiverID: first, Amount: 100},
+		{ReceiverID: sender, Amount: 100},
+	})
+	if !errors.Is(err, ErrBatchRejected) || len(itemErrs) != 2 || !errors.Is(itemErrs[1], ErrSelfTransfer) {
+		t.Errorf("TransferBatch() with a transfer to self = %v, %v, want the second transfer rejected", itemErrs, err)
+	}
+	balances(1000, 1000, 1000)
+
+	// Each transfer is affordable on its own, but not the batch as a whole.
+	_, _, err = svc.TransferBatch(ctx, nil, sender, []model.Transaction{
+		{ReceiverID: first, Amount: 600},
+		{ReceiverID: second, Amount: 600},
+	})
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("TransferBatch() over the balance error = %v, want %v", err, ErrInsufficientFunds)
+	}
+	balances(1000, 1000, 1000)
+
+	if _, _, err := svc.TransferBatch(ctx, nil, sender, []model.Transaction{
+		{ReceiverID: first, Amount: 300},
+		{ReceiverID: second, Amount: 200},
+	}); err != nil {
+		t.Fatalf("TransferBatch() error = %v", err)
+	}
+	balances(500, 1300, 1200)
+	checkBooks(t, db)
+}
+
+// lastTransferID returns the ID of the latest transfer sent by senderID.
+func lastTransferID(t *testing.T, db *sql.DB, senderID int) int {
+	t.Helper()
+	var id int
+	if err := db.QueryRow("SELECT id FROM transactions WHERE sender_id = $1 ORDER BY id DESC LIMIT 1", senderID).Scan(&id); err != nil {
+		t.Fatalf("failed to get transfer of user %d: %v", senderID, err)
+	}
+	return id
+}
+
+func TestCancelTransferWithinWindow(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := NewWalletService(repository.NewUserRepository(db), repository.NewTransactionRepository(db), db, time.Hour, discardLogger())
+	sender, receiver := createTestUser(t, db, 1), createTestUser(t, db, 2)
+
+	if err := svc.Transfer(ctx, model.Transaction{SenderID: sender, ReceiverID: receiver, Amount: 100}); err != nil {
+		t.Fatalf("Transfer() error = %v", err)
+	}
+	id := lastTransferID(t, db, sender)
+
+	if _, err := svc.CancelTransfer(ctx, receiver, id); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("CancelTransfer() by the receiver error = %v, want %v", err, ErrTransactionNotFound)
+	}
+	reversal, err := svc.CancelTransfer(ctx, sender, id)
+	if err != nil {
+		t.Fatalf("CancelTransfer() error = %v", err)
+	}
+	if reversal.ReversesID != id || reversal.SenderID != receiver || reversal.ReceiverID != sender || reversal.Amount != 100 {
+		t.Errorf("CancelTransfer() = %+v, want a reversal of transfer %d", reversal, id)
+	}
+	if got := userCoins(t, db, sender); got != 1000 {
+		t.Errorf("sender has %d coins after the reversal, want 1000", got)
+	}
+
+	if _, err := svc.CancelTransfer(ctx, sender, id); !errors.Is(err, ErrAlreadyReversed) {
+		t.Errorf("second CancelTransfer() error = %v, want %v", err, ErrAlreadyReversed)
+	}
+	if _, err := svc.ReverseTransfer(ctx, id); !errors.Is(err, ErrAlreadyReversed) {
+		t.Errorf("ReverseTransfer() of a reversed transfer error = %v, want %v", err, ErrAlreadyReversed)
+	}
+	if _, err := svc.ReverseTransfer(ctx, reversal.ID); !errors.Is(err, ErrNotReversible) {
+		t.Errorf("ReverseTransfer() of a reversal error = %v, want %v", err, ErrNotReversible)
+	}
+	if got := userCoins(t, db, receiver); got != 1000 {
+		t.Errorf("receiver has %d coins, want 1000", got)
+	}
+	checkBooks(t, db)
+}
+
+func TestCancelTransferAfterWindow(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	svc := NewWalletService(repository.NewUserRepository(db), repository.NewTransactionRepository(db), db, time.Hour, discardLogger())
+	sender, receiver := createTestUser(t, db, 1), createTestUser(t, db, 2)
+
+	if err := svc.Transfer(ctx, model.Transaction{SenderID: sender, ReceiverID: receiver, Amount: 100}); err != nil {
+		t.Fatalf("Transfer() error = %v", err)
+	}
+	id := lastTransferID(t, db, sender)
+	if _, err := db.Exec("UPDATE transactions SET created_at = created_at - INTERVAL '2 hours' WHERE id = $1", id); err != nil {
+		t.Fatalf("failed to age transfer: %v", err)
+	}
+
+	if _, err := svc.CancelTransfer(ctx, sender, id); !errors.Is(err, ErrReversalWindowExpired) {
+		t.Errorf("CancelTransfer() after the window error = %v, want %v", err, ErrReversalWindowExpired)
+	}
+	if got := userCoins(t, db, sender); got != 900 {
+		t.Errorf("sender has %d coins after a rejected cancellation, want 900", got)
+	}
+
+	// An admin may reverse a transfer regardless of the window.
+	if _, err := svc.ReverseTransfer(ctx, id); err != nil {
+		t.Fatalf("ReverseTransfer() error = %v", err)
+	}
+	if got := userCoins(t, db, sender); got != 1000 {
+		t.Errorf("sender has %d coins after the reversal, want 1000", got)
+	}
+	checkBooks(t, db)
+}
+
+// TestConcurrentReversalsReverseOnce reverses the same transfer from several requests at once:
+// exactly one of them may move the coins back.
+func TestConcurrentReversalsReverseOnce(t *testing.T) {
+	db := openTestDB(t)
+	db.SetMaxOpenConns(16)
+	ctx := context.Background()
+	svc := NewWalletService(repository.NewUserRepository(db), repository.NewTransactionRepository(db), db, time.Hour, discardLogger())
+	sender, receiver := createTestUser(t, db, 1), createTestUser(t, db, 2)
+
+	if err := svc.Transfer(ctx, model.Transaction{SenderID: sender, ReceiverID: receiver, Amount: 100}); err != nil {
+		t.Fatalf("Transfer() error = %v", err)
+	}
+	id := lastTransferID(t, db, sender)
+
+	const attempts = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if i%2 == 0 {
+				_, err = svc.CancelTransfer(ctx, sender, id)
+			} else {
+				_, err = svc.ReverseTransfer(ctx, id)
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		} else if !errors.Is(err, ErrAlreadyReversed) {
+			t.Errorf("reversal error = %v, want nil or %v", err, ErrAlreadyReversed)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d reversals succeeded, want 1", succeeded)
+	}
+	if got := userCoins(t, db, receiver); got != 1000 {
+		t.Errorf("receiver has %d coins, want 1000", got)
+	}
+	checkBooks(t, db)
+}
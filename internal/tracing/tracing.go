@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters that can be chosen with TRACING_EXPORTER.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const instrumentationName = "github.com/BAPBAP1/avito-tech-internship-winter-2025"
+
+// Attribute keys set on service spans.
+const (
+	AttrUserID        = attribute.Key("app.user_id")
+	AttrReceiverID    = attribute.Key("app.receiver_id")
+	AttrAmount        = attribute.Key("app.amount")
+	AttrBatchSize     = attribute.Key("app.batch_size")
+	AttrTransactionID = attribute.Key("app.transaction_id")
+	AttrItemID        = attribute.Key("app.item_id")
+	AttrItemName      = attribute.Key("app.item_name")
+	AttrVariantID     = attribute.Key("app.variant_id")
+	AttrSKU           = attribute.Key("app.sku")
+	AttrPromoCode     = attribute.Key("app.promo_code")
+	AttrPurchaseID    = attribute.Key("app.purchase_id")
+	AttrOrderStatus   = attribute.Key("app.order_status")
+)
+
+// Options configure Setup. OTLPEndpoint is a host:port of an OTLP/HTTP collector and is only used by ExporterOTLP.
+type Options struct {
+	Exporter     string
+	OTLPEndpoint string
+	ServiceName  string
+}
+
+// Setup installs the global tracer provider and the W3C trace context propagator, and returns
+// a function that flushes buffered spans on shutdown. With ExporterNone or an empty exporter
+// nothing is installed and every span is a no-op.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(opts.OTLPEndpoint), otlptracehttp.WithInsecure())
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s exporter: %w", opts.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// OpenDB opens a database whose statements are each recorded as a span.
+// Spans are only recorded when Setup has installed an exporter.
+func OpenDB(driverName, dsn string) (*sql.DB, error) {
+	return otelsql.Open(driverName, dsn,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+			DisableErrSkip:       true,
+		}),
+	)
+}
+
+// Start starts a span named name as a child of the span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on span, marking the span failed if err is not nil, and ends it.
+// Call it from a deferred function so it sees the error the traced function returns.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// SetUserID adds the authenticated user to the span in ctx.
+func SetUserID(ctx context.Context, userID int) {
+	trace.SpanFromContext(ctx).SetAttributes(AttrUserID.Int(userID))
+}
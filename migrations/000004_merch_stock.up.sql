@@ -0,0 +1,2 @@
+-- NULL stock means the item is not stock-tracked and can be sold in any quantity.
+ALTER TABLE merch_items ADD COLUMN IF NOT EXISTS stock INTEGER CHECK (stock >= 0);
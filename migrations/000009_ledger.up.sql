@@ -0,0 +1,91 @@
+-- Double-entry ledger. Every movement of coins is a journal entry whose postings sum to zero.
+-- A positive posting increases the account balance, a negative one decreases it.
+-- users.coins is kept as a cached balance of the user's account and is checked against the ledger.
+
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id SERIAL PRIMARY KEY,
+    code TEXT NOT NULL UNIQUE,
+    kind TEXT NOT NULL CHECK (kind IN ('user', 'system')),
+    user_id INTEGER UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    CHECK ((kind = 'user') = (user_id IS NOT NULL))
+);
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id SERIAL PRIMARY KEY,
+    kind TEXT NOT NULL CHECK (kind IN ('transfer', 'purchase', 'grant', 'refund', 'adjustment')),
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS postings (
+    id SERIAL PRIMARY KEY,
+    entry_id INTEGER NOT NULL,
+    account_id INTEGER NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount <> 0),
+    FOREIGN KEY (entry_id) REFERENCES journal_entries(id),
+    FOREIGN KEY (account_id) REFERENCES ledger_accounts(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_postings_entry ON postings(entry_id);
+CREATE INDEX IF NOT EXISTS idx_postings_account ON postings(account_id);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS journal_entry_id INTEGER REFERENCES journal_entries(id);
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS journal_entry_id INTEGER REFERENCES journal_entries(id);
+
+ALTER TABLE users ADD CONSTRAINT users_coins_non_negative CHECK (coins >= 0);
+
+INSERT INTO ledger_accounts (code, kind) VALUES
+    ('initial_grant', 'system'),
+    ('merch_revenue', 'system'),
+    ('admin_adjustment', 'system')
+ON CONFLICT (code) DO NOTHING;
+
+-- Entries are checked at commit time, after all of their postings are written.
+CREATE OR REPLACE FUNCTION check_journal_entry_balanced() RETURNS trigger AS $$
+BEGIN
+    IF (SELECT SUM(amount) FROM postings WHERE entry_id = NEW.entry_id) <> 0 THEN
+        RAISE EXCEPTION 'journal entry % is not balanced', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER postings_balanced
+    AFTER INSERT OR UPDATE ON postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_journal_entry_balanced();
+
+-- Every user gets a ledger account, and the starting coins are booked as a grant.
+CREATE OR REPLACE FUNCTION open_user_ledger_account(p_user_id INTEGER, p_coins INTEGER) RETURNS void AS $$
+DECLARE
+    new_entry_id INTEGER;
+BEGIN
+    INSERT INTO ledger_accounts (code, kind, user_id) VALUES ('user:' || p_user_id, 'user', p_user_id);
+
+    IF p_coins > 0 THEN
+        INSERT INTO journal_entries (kind, description) VALUES ('grant', 'initial grant')
+        RETURNING id INTO new_entry_id;
+
+        INSERT INTO postings (entry_id, account_id, amount)
+        SELECT new_entry_id, id, -p_coins FROM ledger_accounts WHERE code = 'initial_grant';
+        INSERT INTO postings (entry_id, account_id, amount)
+        SELECT new_entry_id, id, p_coins FROM ledger_accounts WHERE user_id = p_user_id;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Existing balances become opening grants.
+SELECT open_user_ledger_account(id, coins) FROM users ORDER BY id;
+
+CREATE OR REPLACE FUNCTION users_open_ledger_account() RETURNS trigger AS $$
+BEGIN
+    PERFORM open_user_ledger_account(NEW.id, NEW.coins);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_open_ledger_account
+    AFTER INSERT ON users
+    FOR EACH ROW EXECUTE FUNCTION users_open_ledger_account();
@@ -0,0 +1,8 @@
+-- Keyset pagination walks these indexes in (created_at, id) descending order.
+CREATE INDEX IF NOT EXISTS idx_transactions_sender_created ON transactions(sender_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_transactions_receiver_created ON transactions(receiver_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_purchases_user_purchased ON purchases(user_id, purchased_at DESC, id DESC);
+
+DROP INDEX IF EXISTS idx_transactions_sender;
+DROP INDEX IF EXISTS idx_transactions_receiver;
+DROP INDEX IF EXISTS idx_purchases_user;
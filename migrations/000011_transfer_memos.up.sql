@@ -0,0 +1,4 @@
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS memo TEXT NOT NULL DEFAULT ''
+    CHECK (char_length(memo) <= 200);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS category TEXT NOT NULL DEFAULT 'other'
+    CHECK (category IN ('kudos', 'reimbursement', 'gift', 'other'));
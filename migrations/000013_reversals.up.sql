@@ -0,0 +1,7 @@
+-- A reversal is a new transfer in the opposite direction that points at the original one.
+-- The unique constraint allows at most one reversal per transfer.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reverses_transaction_id INTEGER UNIQUE REFERENCES transactions(id);
+
+-- A refunded purchase stays in place and points at the compensating journal entry.
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS refunded_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS refund_journal_entry_id INTEGER REFERENCES journal_entries(id);
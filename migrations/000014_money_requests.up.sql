@@ -0,0 +1,22 @@
+-- The requester asks the payer for coins. Only pending requests change status;
+-- an accepted request points at the transfer that paid it.
+CREATE TABLE IF NOT EXISTS money_requests (
+    id SERIAL PRIMARY KEY,
+    requester_id INTEGER NOT NULL,
+    payer_id INTEGER NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    memo TEXT NOT NULL DEFAULT '' CHECK (char_length(memo) <= 200),
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'declined', 'expired', 'cancelled')),
+    transaction_id INTEGER REFERENCES transactions(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY (requester_id) REFERENCES users(id),
+    FOREIGN KEY (payer_id) REFERENCES users(id),
+    CHECK (requester_id <> payer_id),
+    CHECK ((status = 'accepted') = (transaction_id IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_money_requests_payer ON money_requests(payer_id, status);
+CREATE INDEX IF NOT EXISTS idx_money_requests_requester ON money_requests(requester_id, status);
@@ -0,0 +1,18 @@
+-- A hold reserves coins for a merch item without moving them in the ledger.
+-- Active holds that have not expired are subtracted from the available balance.
+CREATE TABLE IF NOT EXISTS holds (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    item_id INTEGER NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    status TEXT NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'captured', 'released', 'expired')),
+    purchase_id INTEGER REFERENCES purchases(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (item_id) REFERENCES merch_items(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_user_active ON holds(user_id, expires_at) WHERE status = 'active';
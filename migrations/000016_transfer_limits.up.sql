@@ -0,0 +1,16 @@
+-- Outgoing transfer limits. The 'global' row applies to everyone; a row for a role
+-- overrides the global value of every column it sets. NULL means no limit (or, in a
+-- role row, the global value). Daily and weekly limits are rolling 24 hour and 7 day windows.
+CREATE TABLE IF NOT EXISTS transfer_limits (
+    scope TEXT PRIMARY KEY CHECK (scope IN ('global', 'user', 'admin', 'auditor')),
+    max_per_transfer INTEGER CHECK (max_per_transfer > 0),
+    max_daily_amount INTEGER CHECK (max_daily_amount > 0),
+    max_weekly_amount INTEGER CHECK (max_weekly_amount > 0),
+    max_daily_count INTEGER CHECK (max_daily_count > 0),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO transfer_limits (scope) VALUES ('global') ON CONFLICT (scope) DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_sender_outgoing ON transactions(sender_id, created_at)
+    WHERE reverses_transaction_id IS NULL;
@@ -0,0 +1,13 @@
+-- Every purchase is an order that goes through fulfillment. Each transition is stamped
+-- with its own timestamp so the history of an order can be shown to the buyer.
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'placed'
+    CHECK (status IN ('placed', 'approved', 'ready_for_pickup', 'delivered', 'cancelled'));
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS ready_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP WITH TIME ZONE;
+
+-- Purchases refunded before orders existed are not going to be fulfilled.
+UPDATE purchases SET status = 'cancelled', cancelled_at = refunded_at WHERE refunded_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_purchases_status ON purchases (status, purchased_at, id);
@@ -0,0 +1,18 @@
+-- Variants split an item into sizes and colors, each sold under its own SKU.
+-- NULL price means the variant costs as much as its item; NULL stock means it is not stock-tracked.
+CREATE TABLE IF NOT EXISTS merch_variants (
+    id SERIAL PRIMARY KEY,
+    merch_item_id INTEGER NOT NULL REFERENCES merch_items(id),
+    sku TEXT NOT NULL UNIQUE,
+    size TEXT NOT NULL DEFAULT '',
+    color TEXT NOT NULL DEFAULT '',
+    price INTEGER CHECK (price > 0),
+    stock INTEGER CHECK (stock >= 0),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (merch_item_id, size, color)
+);
+
+-- The exact variant bought; NULL for items without variants.
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS sku TEXT;
@@ -0,0 +1,44 @@
+-- Categories let a promotion cover a group of items, e.g. all apparel.
+ALTER TABLE merch_items ADD COLUMN IF NOT EXISTS category TEXT NOT NULL DEFAULT '';
+UPDATE merch_items SET category = 'apparel' WHERE name IN ('t-shirt', 'hoody', 'pink-hoody', 'socks') AND category = '';
+
+-- A promotion with a code is applied when the buyer enters it; one without a code applies
+-- automatically to every purchase in its scope. NULL item_name and category mean any item,
+-- NULL ends_at means no end and NULL caps mean no limit.
+CREATE TABLE IF NOT EXISTS promotions (
+    id SERIAL PRIMARY KEY,
+    code TEXT UNIQUE,
+    name TEXT NOT NULL,
+    discount_type TEXT NOT NULL CHECK (discount_type IN ('percent', 'fixed')),
+    discount_value INTEGER NOT NULL CHECK (discount_value > 0),
+    item_name TEXT,
+    category TEXT,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    max_uses INTEGER CHECK (max_uses > 0),
+    max_uses_per_user INTEGER CHECK (max_uses_per_user > 0),
+    uses INTEGER NOT NULL DEFAULT 0,
+    stackable BOOLEAN NOT NULL DEFAULT FALSE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (discount_type <> 'percent' OR discount_value <= 100),
+    CHECK (item_name IS NULL OR category IS NULL),
+    CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+    id SERIAL PRIMARY KEY,
+    promotion_id INTEGER NOT NULL REFERENCES promotions(id),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    purchase_id INTEGER NOT NULL REFERENCES purchases(id),
+    discount INTEGER NOT NULL CHECK (discount > 0),
+    redeemed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotion_redemptions_user ON promotion_redemptions (promotion_id, user_id);
+
+-- price stays what the buyer paid, so refunds return exactly that.
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS list_price INTEGER;
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS discount INTEGER NOT NULL DEFAULT 0 CHECK (discount >= 0);
+UPDATE purchases SET list_price = price WHERE list_price IS NULL;
+ALTER TABLE purchases ALTER COLUMN list_price SET NOT NULL;
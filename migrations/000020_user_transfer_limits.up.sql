@@ -0,0 +1,21 @@
+-- 0 now means "no limit" and, unlike NULL, also lifts the limit a role or user row would
+-- otherwise inherit, so a role can be exempted from a global limit.
+ALTER TABLE transfer_limits DROP CONSTRAINT IF EXISTS transfer_limits_max_per_transfer_check;
+ALTER TABLE transfer_limits DROP CONSTRAINT IF EXISTS transfer_limits_max_daily_amount_check;
+ALTER TABLE transfer_limits DROP CONSTRAINT IF EXISTS transfer_limits_max_weekly_amount_check;
+ALTER TABLE transfer_limits DROP CONSTRAINT IF EXISTS transfer_limits_max_daily_count_check;
+ALTER TABLE transfer_limits ADD CONSTRAINT transfer_limits_max_per_transfer_check CHECK (max_per_transfer >= 0);
+ALTER TABLE transfer_limits ADD CONSTRAINT transfer_limits_max_daily_amount_check CHECK (max_daily_amount >= 0);
+ALTER TABLE transfer_limits ADD CONSTRAINT transfer_limits_max_weekly_amount_check CHECK (max_weekly_amount >= 0);
+ALTER TABLE transfer_limits ADD CONSTRAINT transfer_limits_max_daily_count_check CHECK (max_daily_count >= 0);
+
+-- Limits of a single user override those of their role the same way a role overrides
+-- the global row: NULL inherits, 0 means no limit.
+CREATE TABLE IF NOT EXISTS user_transfer_limits (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    max_per_transfer INTEGER CHECK (max_per_transfer >= 0),
+    max_daily_amount INTEGER CHECK (max_daily_amount >= 0),
+    max_weekly_amount INTEGER CHECK (max_weekly_amount >= 0),
+    max_daily_count INTEGER CHECK (max_daily_count >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
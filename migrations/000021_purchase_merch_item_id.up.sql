@@ -0,0 +1,12 @@
+-- Purchases keep the item name they were sold under, which goes stale when an item is
+-- renamed, so they also reference the item itself. Purchases of items renamed before this
+-- migration cannot be matched and keep a NULL merch_item_id.
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS merch_item_id INTEGER REFERENCES merch_items(id);
+
+UPDATE purchases p SET merch_item_id = v.merch_item_id
+FROM merch_variants v
+WHERE p.merch_item_id IS NULL AND p.sku = v.sku;
+
+UPDATE purchases p SET merch_item_id = m.id
+FROM merch_items m
+WHERE p.merch_item_id IS NULL AND p.item_name = m.name;
@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL migrations, so the binary carries the schema version it
+// expects instead of reading it from the directory it happens to run in.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS